@@ -0,0 +1,126 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// Sub-command env prints the effective environment of each command of a target.
+// It provides a way to debug the layering of the Global, Target, and Exec environment
+// configuration without running the commands.
+//
+// Usage:
+//
+//	boone env --config /path/to/config <target Id or Label>
+//	boone env --config /path/to/config --changed <target Id or Label>
+package env
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/codeactual/boone/internal/boone"
+	"github.com/codeactual/boone/internal/cage/cli/handler"
+	handler_cobra "github.com/codeactual/boone/internal/cage/cli/handler/cobra"
+)
+
+// Handler defines the sub-command flags and logic.
+type Handler struct {
+	handler.Session
+
+	ConfigPath string
+
+	// Changed is true if only the variables which differ from the boone process environment are printed.
+	Changed bool
+}
+
+// Init defines the command, its environment variable prefix, etc.
+//
+// It implements cli/handler/cobra.Handler.
+func (h *Handler) Init() handler_cobra.Init {
+	return handler_cobra.Init{
+		Cmd: &cobra.Command{
+			Use:   "env",
+			Short: "Print the effective environment of each command of a target",
+			Example: strings.Join([]string{
+				"boone env --config /path/to/config some_target_id",
+				"boone env --config /path/to/config --changed \"some target label\"",
+			}, "\n"),
+			Args: cobra.ExactArgs(1),
+		},
+		EnvPrefix: "BOONE",
+	}
+}
+
+// BindFlags binds the flags to Handler fields.
+//
+// It implements cli/handler/cobra.Handler.
+func (l *Handler) BindFlags(cmd *cobra.Command) []string {
+	cmd.Flags().StringVarP(&l.ConfigPath, "config", "c", "", "viper-readable config file")
+	cmd.Flags().BoolVarP(&l.Changed, "changed", "", false, "only print variables which differ from the current environment")
+	return []string{"config"}
+}
+
+// Run performs the sub-command logic.
+//
+// It implements cli/handler/cobra.Handler.
+func (l *Handler) Run(ctx context.Context, input handler.Input) {
+	err := l.run(input.Args[0])
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (l Handler) run(targetName string) error {
+	cfg, err := boone.ReadConfigFile(l.ConfigPath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	var target *boone.Target
+	for n := range cfg.Target {
+		if cfg.Target[n].Id == targetName || cfg.Target[n].Label == targetName {
+			target = &cfg.Target[n]
+			break
+		}
+	}
+	if target == nil {
+		return errors.Errorf("target [%s] not found", targetName)
+	}
+
+	for _, h := range target.Handler {
+		for _, e := range h.Exec {
+			env, err := e.Environ()
+			if err != nil {
+				return errors.Wrapf(err, "[target: %s]: failed to read handler [%s] command [%s] environment", target.Label, h.Label, e.Cmd)
+			}
+
+			fmt.Printf("# handler [%s] command [%s]\n", h.Label, e.Cmd)
+			for _, pair := range env {
+				kv := strings.SplitN(pair, "=", 2)
+				if l.Changed {
+					if v, ok := os.LookupEnv(kv[0]); ok && v == kv[1] {
+						continue
+					}
+				}
+				fmt.Println(pair)
+			}
+			fmt.Println()
+		}
+	}
+
+	return nil
+}
+
+// New returns a cobra command instance based on Handler.
+func NewCommand() *cobra.Command {
+	return handler_cobra.NewHandler(&Handler{
+		Session: &handler.DefaultSession{},
+	})
+}
+
+var _ handler_cobra.Handler = (*Handler)(nil)
@@ -0,0 +1,390 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// Sub-command hook manages git pre-commit/pre-push hooks which refuse a commit/push if any target
+// covering the changed paths is failing or has not finished running.
+//
+// The "install" and "uninstall" actions manage hooks in every git repository which contains a
+// Target.Root. Hooks which were not written by "install" are never modified.
+//
+// The "run" action is executed by the installed hooks. It reads the status list from a running
+// instance if Data.Control.File is configured and accepting connections, otherwise from Data.Session.File.
+// Set BOONE_HOOK_SKIP=1 to bypass the check. If the check itself fails, e.g. no status list exists yet,
+// it prints a warning and allows the commit/push.
+//
+// Usage:
+//
+//	boone hook install --config /path/to/config
+//	boone hook install --config /path/to/config --hook pre-push
+//	boone hook uninstall --config /path/to/config
+//	boone hook run --config /path/to/config pre-commit
+package hook
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/codeactual/boone/internal/boone"
+	"github.com/codeactual/boone/internal/cage/cli/handler"
+	handler_cobra "github.com/codeactual/boone/internal/cage/cli/handler/cobra"
+	cage_file "github.com/codeactual/boone/internal/cage/os/file"
+)
+
+const (
+	// ExitBlocked is the exit code used by the "run" action when the commit/push is refused.
+	ExitBlocked = 1
+
+	// ExitError is the exit code used when the hooks could not be managed.
+	ExitError = 2
+
+	// emptyTree is the hash of git's empty tree object, used to diff a newly pushed ref against nothing.
+	emptyTree = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+
+	// hookPerm is the permission granted to new hook files.
+	hookPerm = 0755
+)
+
+// Handler defines the sub-command flags and logic.
+type Handler struct {
+	handler.Session
+
+	ConfigPath string
+
+	// Hook selects which hooks "install" and "uninstall" manage.
+	Hook []string
+}
+
+// Init defines the command, its environment variable prefix, etc.
+//
+// It implements cli/handler/cobra.Handler.
+func (h *Handler) Init() handler_cobra.Init {
+	return handler_cobra.Init{
+		Cmd: &cobra.Command{
+			Use:   "hook install|uninstall|run",
+			Short: "Manage git hooks which refuse commits/pushes while covering targets are failing or unfinished",
+			Example: strings.Join([]string{
+				"boone hook install --config /path/to/config",
+				"boone hook install --config /path/to/config --hook pre-push",
+				"boone hook uninstall --config /path/to/config",
+				"boone hook run --config /path/to/config pre-commit",
+			}, "\n"),
+		},
+		EnvPrefix: "BOONE",
+	}
+}
+
+// BindFlags binds the flags to Handler fields.
+//
+// It implements cli/handler/cobra.Handler.
+func (h *Handler) BindFlags(cmd *cobra.Command) []string {
+	cmd.Flags().StringVarP(&h.ConfigPath, "config", "c", "", "viper-readable config file")
+	cmd.Flags().StringSliceVarP(&h.Hook, "hook", "", []string{boone.HookPreCommit, boone.HookPrePush}, "hook to install/uninstall (repeatable)")
+	return []string{"config"}
+}
+
+// Run performs the sub-command logic.
+//
+// It implements cli/handler/cobra.Handler.
+func (h *Handler) Run(ctx context.Context, input handler.Input) {
+	if len(input.Args) == 0 {
+		h.Exitf(ExitError, "expected an action: install, uninstall, or run")
+	}
+
+	if input.Args[0] == "run" {
+		h.run(input.Args[1:])
+		return
+	}
+
+	cfg, err := boone.ReadConfigFile(h.ConfigPath)
+	h.ExitOnErrShort(err, fmt.Sprintf("failed to read config file [%s]", h.ConfigPath), ExitError)
+
+	switch input.Args[0] {
+	case "install":
+		h.ExitOnErrShort(h.install(cfg), "failed to install hooks", ExitError)
+	case "uninstall":
+		h.ExitOnErrShort(h.uninstall(cfg), "failed to uninstall hooks", ExitError)
+	default:
+		h.Exitf(ExitError, "unsupported action [%s]", input.Args[0])
+	}
+}
+
+// run performs the "run" action.
+//
+// It only refuses the commit/push, with ExitBlocked, if a target is blocking. Other errors, e.g. from
+// a missing status list or an unfetched remote commit, are printed as warnings so they do not refuse
+// every commit/push until the user finds HookSkipEnv.
+func (h *Handler) run(args []string) {
+	if os.Getenv(boone.HookSkipEnv) != "" {
+		return
+	}
+
+	warn := func(err error) {
+		fmt.Fprintf(h.Err(), "boone: warning: skipped the target status check: %s\n", err)
+	}
+
+	if len(args) == 0 {
+		warn(errors.Errorf("expected a hook name: %s or %s", boone.HookPreCommit, boone.HookPrePush))
+		return
+	}
+	hookName := args[0]
+
+	cfg, err := boone.ReadConfigFile(h.ConfigPath)
+	if err != nil {
+		warn(errors.Wrapf(err, "failed to read config file [%s]", h.ConfigPath))
+		return
+	}
+
+	blocking, err := h.check(cfg, hookName)
+	if err != nil {
+		warn(err)
+		return
+	}
+	if len(blocking) > 0 {
+		fmt.Fprintf(h.Err(), "boone: %s refused, %d target(s) covering the changes are failing or unfinished:\n", hookName, len(blocking))
+		for _, status := range blocking {
+			if status.HandlerLabel == "" {
+				fmt.Fprintf(h.Err(), "  - %s | %s\n", status.TargetLabel, status.Cause)
+			} else {
+				fmt.Fprintf(h.Err(), "  - %s | %s | %s\n", status.TargetLabel, status.HandlerLabel, status.Cause)
+			}
+		}
+		fmt.Fprintf(h.Err(), "Set %s=1 to bypass.\n", boone.HookSkipEnv)
+		os.Exit(ExitBlocked)
+	}
+}
+
+// install writes the selected hooks into every repository which contains a Target.Root.
+func (h *Handler) install(cfg boone.Config) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return errors.Wrap(err, "failed to get executable path")
+	}
+	configPath, err := filepath.Abs(h.ConfigPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get absolute path of config [%s]", h.ConfigPath)
+	}
+
+	hookDirs, err := findHookDirs(cfg)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	for _, dir := range hookDirs {
+		if err = os.MkdirAll(dir, hookPerm); err != nil {
+			return errors.Wrapf(err, "failed to create hooks dir [%s]", dir)
+		}
+		for _, name := range h.Hook {
+			if name != boone.HookPreCommit && name != boone.HookPrePush {
+				return errors.Errorf("unsupported hook [%s]", name)
+			}
+
+			p := filepath.Join(dir, name)
+
+			managed, exists, err := isManaged(p)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			if exists && !managed {
+				fmt.Fprintf(h.Err(), "skipped [%s]: existing hook was not installed by boone\n", p)
+				continue
+			}
+
+			if err = ioutil.WriteFile(p, []byte(boone.HookScript(executable, configPath, name)), hookPerm); err != nil {
+				return errors.Wrapf(err, "failed to write hook [%s]", p)
+			}
+			if err = os.Chmod(p, hookPerm); err != nil { // in case it already existed with other permissions
+				return errors.Wrapf(err, "failed to set hook [%s] permissions", p)
+			}
+			fmt.Fprintf(h.Out(), "installed [%s]\n", p)
+		}
+	}
+
+	return nil
+}
+
+// uninstall removes the selected hooks, if written by install, from every repository which contains a Target.Root.
+func (h *Handler) uninstall(cfg boone.Config) error {
+	hookDirs, err := findHookDirs(cfg)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	for _, dir := range hookDirs {
+		for _, name := range h.Hook {
+			p := filepath.Join(dir, name)
+
+			managed, _, err := isManaged(p)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			if !managed {
+				continue
+			}
+
+			if err = os.Remove(p); err != nil {
+				return errors.Wrapf(err, "failed to remove hook [%s]", p)
+			}
+			fmt.Fprintf(h.Out(), "uninstalled [%s]\n", p)
+		}
+	}
+
+	return nil
+}
+
+// check returns the statuses which should refuse the commit/push in the current working directory's repository.
+func (h *Handler) check(cfg boone.Config, hookName string) ([]boone.Status, error) {
+	top, err := git("", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return []boone.Status{}, errors.WithStack(err)
+	}
+
+	var relPaths []string
+	switch hookName {
+	case boone.HookPreCommit:
+		out, err := git(top, "diff", "--cached", "--name-only", "-z", "--diff-filter=ACMR")
+		if err != nil {
+			return []boone.Status{}, errors.WithStack(err)
+		}
+		relPaths = splitPaths(out)
+	case boone.HookPrePush:
+		// Each stdin line has the format: <local ref> <local sha1> <remote ref> <remote sha1>
+		in := h.In()
+		if in == nil {
+			return []boone.Status{}, nil
+		}
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) != 4 {
+				continue
+			}
+			localSha, remoteSha := fields[1], fields[3]
+			if strings.Trim(localSha, "0") == "" { // ref deletion
+				continue
+			}
+			if strings.Trim(remoteSha, "0") == "" { // new ref
+				remoteSha = emptyTree
+			}
+			out, err := git(top, "diff", "--name-only", "-z", remoteSha, localSha)
+			if err != nil {
+				return []boone.Status{}, errors.WithStack(err)
+			}
+			relPaths = append(relPaths, splitPaths(out)...)
+		}
+		if err = scanner.Err(); err != nil {
+			return []boone.Status{}, errors.Wrap(err, "failed to read pre-push refs from standard input")
+		}
+	default:
+		return []boone.Status{}, errors.Errorf("unsupported hook [%s]", hookName)
+	}
+
+	if len(relPaths) == 0 {
+		return []boone.Status{}, nil
+	}
+
+	paths := make([]string, len(relPaths))
+	for n, p := range relPaths {
+		paths[n] = filepath.Join(top, p)
+	}
+
+	session, _, err := boone.ReadSession(cfg)
+	if err != nil {
+		return []boone.Status{}, errors.WithStack(err)
+	}
+
+	blocking, err := boone.BlockingStatus(cfg.Target, session.Statuses, paths)
+	if err != nil {
+		return []boone.Status{}, errors.WithStack(err)
+	}
+
+	return blocking, nil
+}
+
+// findHookDirs returns the unique hooks directories of all repositories which contain a Target.Root.
+func findHookDirs(cfg boone.Config) (dirs []string, err error) {
+	seen := map[string]bool{}
+
+	for _, t := range cfg.Target {
+		if len(t.Include) == 0 {
+			continue
+		}
+
+		top, err := git(t.Root, "rev-parse", "--show-toplevel")
+		if err != nil {
+			continue // not a repository
+		}
+
+		hooks, err := git(top, "rev-parse", "--git-path", "hooks")
+		if err != nil {
+			return []string{}, errors.WithStack(err)
+		}
+		if !filepath.IsAbs(hooks) {
+			hooks = filepath.Join(top, hooks)
+		}
+
+		if !seen[hooks] {
+			seen[hooks] = true
+			dirs = append(dirs, hooks)
+		}
+	}
+
+	return dirs, nil
+}
+
+// isManaged reports whether the hook file exists and was written by install.
+func isManaged(name string) (managed bool, exists bool, err error) {
+	exists, _, err = cage_file.Exists(name)
+	if err != nil {
+		return false, false, errors.Wrapf(err, "failed to inspect hook [%s]", name)
+	}
+	if !exists {
+		return false, false, nil
+	}
+	b, err := ioutil.ReadFile(name) // #nosec G304
+	if err != nil {
+		return false, true, errors.Wrapf(err, "failed to read hook [%s]", name)
+	}
+	return strings.Contains(string(b), boone.HookMarker), true, nil
+}
+
+// git runs a git command in the directory and returns its trimmed standard output.
+func git(dir string, arg ...string) (string, error) {
+	cmd := exec.Command("git", arg...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to run git %s in [%s]", strings.Join(arg, " "), dir)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// splitPaths splits the NUL-separated output of "git diff -z --name-only".
+func splitPaths(s string) (paths []string) {
+	for _, p := range strings.Split(s, "\x00") {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// New returns a cobra command instance based on Handler.
+func NewCommand() *cobra.Command {
+	return handler_cobra.NewHandler(&Handler{
+		Session: &handler.DefaultSession{},
+	})
+}
+
+var _ handler_cobra.Handler = (*Handler)(nil)
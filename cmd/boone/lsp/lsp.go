@@ -0,0 +1,97 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// Sub-command lsp is a Language Server Protocol front-end, communicating over stdio, which lets editors
+// display the failures of a running instance inline.
+//
+// It publishes "textDocument/publishDiagnostics" for the file locations which Exec.ProblemMatcher found
+// in failing handler output, and clears them when the target passes. Code actions offer to rerun the
+// target, which requires Data.Control.File, and to show its full output.
+//
+// It polls the status list from a running instance if Data.Control.File is configured and accepting
+// connections, otherwise from Data.Session.File.
+//
+// Usage:
+//
+//	boone lsp --config /path/to/config
+//	boone lsp --config /path/to/config --log-file /path/to/lsp.log --log-level debug
+package lsp
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/codeactual/boone/internal/boone"
+	"github.com/codeactual/boone/internal/cage/cli/handler"
+	handler_cobra "github.com/codeactual/boone/internal/cage/cli/handler/cobra"
+	log_zap "github.com/codeactual/boone/internal/cage/cli/handler/mixin/log/zap"
+)
+
+// Handler defines the sub-command flags and logic.
+type Handler struct {
+	handler.Session
+
+	ConfigPath string
+
+	Log *log_zap.Mixin
+}
+
+// Init defines the command, its environment variable prefix, etc.
+//
+// It implements cli/handler/cobra.Handler.
+func (h *Handler) Init() handler_cobra.Init {
+	h.Log = &log_zap.Mixin{}
+	return handler_cobra.Init{
+		Cmd: &cobra.Command{
+			Use:   "lsp",
+			Short: "Publish failures as Language Server Protocol diagnostics over stdio",
+			Example: strings.Join([]string{
+				"boone lsp --config /path/to/config",
+				"boone lsp --config /path/to/config --log-file /path/to/lsp.log --log-level debug",
+			}, "\n"),
+		},
+		EnvPrefix: "BOONE",
+		Mixins: []handler.Mixin{
+			h.Log,
+		},
+	}
+}
+
+// BindFlags binds the flags to Handler fields.
+//
+// It implements cli/handler/cobra.Handler.
+func (h *Handler) BindFlags(cmd *cobra.Command) []string {
+	cmd.Flags().StringVarP(&h.ConfigPath, "config", "c", "", "viper-readable config file")
+	return []string{"config"}
+}
+
+// Run performs the sub-command logic.
+//
+// It implements cli/handler/cobra.Handler.
+func (h *Handler) Run(ctx context.Context, input handler.Input) {
+	cfg, err := boone.ReadConfigFile(h.ConfigPath)
+	h.ExitOnErrShort(err, "failed to read config file ["+h.ConfigPath+"]", 1)
+
+	in := h.In()
+	if in == nil { // e.g. a socket instead of a pipe
+		in = os.Stdin
+	}
+
+	server := boone.NewLSPServer(h.Log.Logger, cfg, in, h.Out())
+	h.ExitOnErrShort(server.Serve(ctx), "failed to serve LSP client", 1)
+}
+
+// New returns a cobra command instance based on Handler.
+func NewCommand() *cobra.Command {
+	return handler_cobra.NewHandler(&Handler{
+		Session: &handler.DefaultSession{},
+	})
+}
+
+var _ handler_cobra.Handler = (*Handler)(nil)
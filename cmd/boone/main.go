@@ -7,9 +7,14 @@
 package main
 
 import (
+	"github.com/codeactual/boone/cmd/boone/env"
 	"github.com/codeactual/boone/cmd/boone/eval"
+	"github.com/codeactual/boone/cmd/boone/hook"
+	"github.com/codeactual/boone/cmd/boone/lsp"
 	"github.com/codeactual/boone/cmd/boone/root"
 	"github.com/codeactual/boone/cmd/boone/run"
+	"github.com/codeactual/boone/cmd/boone/status"
+	"github.com/codeactual/boone/cmd/boone/wait"
 
 	"github.com/pkg/errors"
 )
@@ -18,6 +23,11 @@ func main() {
 	rootCmd := root.NewCommand()
 	rootCmd.AddCommand(run.NewCommand())
 	rootCmd.AddCommand(eval.NewCommand())
+	rootCmd.AddCommand(env.NewCommand())
+	rootCmd.AddCommand(status.NewCommand())
+	rootCmd.AddCommand(wait.NewCommand())
+	rootCmd.AddCommand(hook.NewCommand())
+	rootCmd.AddCommand(lsp.NewCommand())
 	if err := rootCmd.Execute(); err != nil {
 		panic(errors.Wrap(err, "failed to execute command"))
 	}
@@ -30,7 +30,6 @@ import (
 	cage_gob "github.com/codeactual/boone/internal/cage/encoding/gob"
 	cage_zap "github.com/codeactual/boone/internal/cage/log/zap"
 	cage_exec "github.com/codeactual/boone/internal/cage/os/exec"
-	cage_file "github.com/codeactual/boone/internal/cage/os/file"
 )
 
 // Handler defines the sub-command flags and logic.
@@ -95,22 +94,13 @@ func (h *Handler) Run(ctx context.Context, input handler.Input) {
 	var seedStatusList []boone.Status
 
 	if cfg.Data.Session.File != "" {
-		exists, fi, err := cage_file.Exists(cfg.Data.Session.File)
-		if err != nil {
-			panic(errors.Wrapf(err, "failed to inspect session file"))
+		decSession, decodeErr := boone.ReadSessionFile(cfg.Data.Session.File)
+		if decodeErr != nil {
+			panic(errors.WithStack(decodeErr))
 		}
-		if exists && fi.Size() > 0 {
-			var sessionTarget []string
-
-			dec, decodeErr := cage_gob.DecodeFromFile(cfg.Data.Session.File)
-			if decodeErr != nil {
-				panic(errors.Wrapf(decodeErr, "failed to create session file decoder"))
-			}
 
-			var decSession boone.Session
-			if decodeErr = dec.Decode(&decSession); decodeErr != nil {
-				panic(errors.Wrapf(decodeErr, "failed to decode session file"))
-			}
+		if len(decSession.Statuses) > 0 {
+			var sessionTarget []string
 
 			h.Log.Debug(
 				"decoded session",
@@ -120,11 +110,17 @@ func (h *Handler) Run(ctx context.Context, input handler.Input) {
 			)
 
 			for _, status := range decSession.Statuses {
+				// Skip downstream targets which were waiting in a tree run. The run's own status, if it was
+				// in progress, is resumed below with the whole tree.
+				if status.Cause == boone.TargetPending && status.UpstreamTargetLabel != "" {
+					continue
+				}
+
 				// Handle case where status was resumed in a prior session but never executed because the program shutdown,
-				// or pending but not yet started before the shutdown.
+				// or debouncing/pending but not yet started before the shutdown.
 				//
 				// Switch the cause back to TargetStarted so the rest of the logic treats the status like it's the first time.
-				if status.Cause == boone.TargetResumed || status.Cause == boone.TargetPending {
+				if status.Cause == boone.TargetResumed || status.Cause == boone.TargetPending || status.Cause == boone.TargetDebouncing {
 					status.Cause = boone.TargetStarted
 				}
 
@@ -158,17 +154,55 @@ func (h *Handler) Run(ctx context.Context, input handler.Input) {
 		}
 	}
 
-	ui := boone.NewUI(h.Log.Logger, dispatcher.TargetStartCh, dispatcher.TargetPassCh, dispatcher.TargetFailCh, seedStatusList)
+	ui := boone.NewUI(h.Log.Logger, cfg.Target, dispatcher.ExecReqCh, dispatcher.TargetStartCh, dispatcher.TargetPassCh, dispatcher.TargetFailCh, seedStatusList)
+	ui.WatchedPathCount = dispatcher.WatchedPathCount
+	ui.Editor = cfg.Global.Editor
 	ui.Init()
 
+	var control *boone.ControlServer
+	if cfg.Data.Control.File != "" {
+		control, err = boone.NewControlServer(h.Log.Logger, cfg.Data.Control.File)
+		if err != nil {
+			h.Log.Error("failed to init control socket", zap.Error(err))
+			os.Exit(1)
+		}
+		control.SetSession(boone.Session{Statuses: seedStatusList, Version: boone.SessionVersion})
+		control.EnableRerun(cfg.Target, dispatcher.ExecReqCh)
+		go control.Start()
+	}
+
+	// Replace any list written by a prior session which did not shut down cleanly.
+	if err = boone.WriteQuickfixFile(cfg.Data.Quickfix, seedStatusList); err != nil {
+		h.Log.Error("failed to write quickfix file", zap.Error(err))
+		os.Exit(1)
+	}
+
 	shutdown := func() {
 		dispatcher.Stop()
 		ui.Stop()
+		if control != nil {
+			control.Stop()
+		}
 	}
 
 	go func() {
+		// Let readers of the session file, e.g. the "wait" sub-command, tell it apart from one left behind.
+		heartbeat := time.NewTicker(boone.SessionFileHeartbeat)
+		defer heartbeat.Stop()
+
 		for {
 			select {
+			case <-heartbeat.C:
+				if cfg.Data.Session.File == "" {
+					continue
+				}
+				if touchErr := boone.TouchSessionFile(cfg.Data.Session.File); touchErr != nil {
+					h.Log.Error(
+						"failed to update session file",
+						cage_zap.Tag("root"),
+						zap.Error(touchErr),
+					)
+				}
 			case r := <-panicCh:
 				shutdown()
 				fmt.Printf("panic from watcher: %+v\n", r)
@@ -177,6 +211,16 @@ func (h *Handler) Run(ctx context.Context, input handler.Input) {
 				for _, status := range session.Statuses {
 					sessionTarget = append(sessionTarget, status.TargetLabel)
 				}
+				if control != nil {
+					control.SetSession(session)
+				}
+				if quickfixErr := boone.WriteQuickfixFile(cfg.Data.Quickfix, session.Statuses); quickfixErr != nil {
+					h.Log.Error(
+						"failed to write quickfix file",
+						cage_zap.Tag("root"),
+						zap.Error(quickfixErr),
+					)
+				}
 				if encodeErr := cage_gob.EncodeToFile(cfg.Data.Session.File, session); encodeErr != nil {
 					h.Log.Error(
 						"failed to encode session file",
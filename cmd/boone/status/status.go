@@ -0,0 +1,229 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// Sub-command status reports failing, pending, and running targets, e.g. for shell prompts and scripts.
+//
+// It reads the status list from a running instance if Data.Control.File is configured and accepting
+// connections, otherwise from Data.Session.File.
+//
+// It exits with code 0 if no target is failing, 1 if at least one target is failing, and 2 if the
+// status list could not be read.
+//
+// Usage:
+//
+//	boone status --config /path/to/config
+//	boone status --config /path/to/config --format json
+//	boone status --config /path/to/config --format line
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/codeactual/boone/internal/boone"
+	"github.com/codeactual/boone/internal/cage/cli/handler"
+	handler_cobra "github.com/codeactual/boone/internal/cage/cli/handler/cobra"
+	cage_time "github.com/codeactual/boone/internal/cage/time"
+)
+
+const (
+	// ExitFailing is the exit code used when at least one target is failing.
+	ExitFailing = 1
+
+	// ExitError is the exit code used when the status list could not be read.
+	ExitError = 2
+
+	// FormatHuman selects a multi-line report grouped by status.
+	FormatHuman = "human"
+
+	// FormatJSON selects a JSON object with one list per status group.
+	FormatJSON = "json"
+
+	// FormatLine selects a single line, e.g. "boone: 2 failing", for shell prompts.
+	FormatLine = "line"
+)
+
+// Handler defines the sub-command flags and logic.
+type Handler struct {
+	handler.Session
+
+	ConfigPath string
+
+	Format string
+}
+
+// Init defines the command, its environment variable prefix, etc.
+//
+// It implements cli/handler/cobra.Handler.
+func (h *Handler) Init() handler_cobra.Init {
+	return handler_cobra.Init{
+		Cmd: &cobra.Command{
+			Use:   "status",
+			Short: "Report failing, pending, and running targets",
+			Example: strings.Join([]string{
+				"boone status --config /path/to/config",
+				"boone status --config /path/to/config --format json",
+				"boone status --config /path/to/config --format line",
+			}, "\n"),
+		},
+		EnvPrefix: "BOONE",
+	}
+}
+
+// BindFlags binds the flags to Handler fields.
+//
+// It implements cli/handler/cobra.Handler.
+func (h *Handler) BindFlags(cmd *cobra.Command) []string {
+	cmd.Flags().StringVarP(&h.ConfigPath, "config", "c", "", "viper-readable config file")
+	cmd.Flags().StringVarP(&h.Format, "format", "f", FormatHuman, "output format: human, json, line")
+	return []string{"config"}
+}
+
+// Run performs the sub-command logic.
+//
+// It implements cli/handler/cobra.Handler.
+func (h *Handler) Run(ctx context.Context, input handler.Input) {
+	summary, err := h.run()
+	h.ExitOnErrShort(err, "failed to report status", ExitError)
+
+	if len(summary.Failing) > 0 {
+		os.Exit(ExitFailing)
+	}
+}
+
+func (h *Handler) run() (summary boone.StatusSummary, err error) {
+	cfg, err := boone.ReadConfigFile(h.ConfigPath)
+	if err != nil {
+		return boone.StatusSummary{}, errors.Wrapf(err, "failed to read config file [%s]", h.ConfigPath)
+	}
+
+	session, src, err := boone.ReadSession(cfg)
+	if err != nil {
+		return boone.StatusSummary{}, errors.WithStack(err)
+	}
+
+	summary = session.Summary()
+
+	switch h.Format {
+	case FormatHuman:
+		writeHuman(h.Out(), src, summary)
+	case FormatJSON:
+		err = writeJSON(h.Out(), src, summary)
+	case FormatLine:
+		fmt.Fprintln(h.Out(), Line(summary))
+	default:
+		return boone.StatusSummary{}, errors.Errorf("unsupported format [%s]", h.Format)
+	}
+
+	return summary, errors.WithStack(err)
+}
+
+// Line returns a single-line summary, e.g. "boone: 2 failing, 1 running", or "boone: ok" if no
+// target is failing, pending, running, warned, or flaky.
+func Line(s boone.StatusSummary) string {
+	var parts []string
+	if n := len(s.Failing); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d failing", n))
+	}
+	if n := len(s.Pending); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d pending", n))
+	}
+	if n := len(s.Running); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d running", n))
+	}
+	if n := len(s.Warned); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d warned", n))
+	}
+	if n := len(s.Flaky); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d flaky", n))
+	}
+	if len(parts) == 0 {
+		return "boone: ok"
+	}
+	return "boone: " + strings.Join(parts, ", ")
+}
+
+func writeHuman(w io.Writer, src boone.SessionSource, s boone.StatusSummary) {
+	fmt.Fprintf(w, "Source: %s\n", src)
+
+	group := func(title string, statuses []boone.Status, describe func(boone.Status) string) {
+		fmt.Fprintf(w, "%s (%d)\n", title, len(statuses))
+		for _, status := range statuses {
+			fmt.Fprintf(w, "  - %s\n", describe(status))
+		}
+	}
+
+	group("Failing", s.Failing, func(status boone.Status) string {
+		return fmt.Sprintf("%s | %s | %s%s", status.TargetLabel, status.HandlerLabel, status.Cause, ago(status.EndTime))
+	})
+	group("Pending", s.Pending, func(status boone.Status) string {
+		return fmt.Sprintf("%s | %s", status.TargetLabel, status.Cause)
+	})
+	group("Running", s.Running, func(status boone.Status) string {
+		return fmt.Sprintf("%s | %s | %s%s", status.TargetLabel, status.HandlerLabel, status.Cause, ago(status.StartTime))
+	})
+	group("Warned", s.Warned, func(status boone.Status) string {
+		return fmt.Sprintf("%s | %s | %s%s", status.TargetLabel, status.HandlerLabel, status.Cause, ago(status.EndTime))
+	})
+	group("Flaky", s.Flaky, func(status boone.Status) string {
+		return fmt.Sprintf("%s | %s%s", status.TargetLabel, strings.Join(status.Flaky, ", "), ago(status.EndTime))
+	})
+}
+
+func writeJSON(w io.Writer, src boone.SessionSource, s boone.StatusSummary) error {
+	out := struct {
+		Source  boone.SessionSource
+		Failing []boone.Status
+		Pending []boone.Status
+		Running []boone.Status
+		Warned  []boone.Status
+		Flaky   []boone.Status
+	}{
+		Source:  src,
+		Failing: append([]boone.Status{}, s.Failing...),
+		Pending: append([]boone.Status{}, s.Pending...),
+		Running: append([]boone.Status{}, s.Running...),
+		Warned:  append([]boone.Status{}, s.Warned...),
+		Flaky:   append([]boone.Status{}, s.Flaky...),
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return errors.Wrap(err, "failed to encode status as JSON")
+	}
+	return nil
+}
+
+// ago returns a UI-style relative time suffix, e.g. " @ now" or " @ 5m ago", or an empty string if the
+// time is unknown.
+func ago(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	age := time.Since(t)
+	if age < time.Minute {
+		return " @ now"
+	}
+	return " @ " + cage_time.DurationShort(age) + " ago"
+}
+
+// New returns a cobra command instance based on Handler.
+func NewCommand() *cobra.Command {
+	return handler_cobra.NewHandler(&Handler{
+		Session: &handler.DefaultSession{},
+	})
+}
+
+var _ handler_cobra.Handler = (*Handler)(nil)
@@ -0,0 +1,255 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// Sub-command wait blocks until no target is debouncing, pending, or running, e.g. so that a
+// pre-push script can wait for the latest edits to be processed.
+//
+// Downstream targets of a tree run are listed as pending until they run, so the tree as a whole
+// is waited on, including the time between its targets.
+//
+// It polls the status list from a running instance if Data.Control.File is configured and accepting
+// connections, otherwise from Data.Session.File. If Data.Control.File is configured but no instance is
+// accepting connections, it exits with an error because the session file's debouncing/pending/running
+// statuses are from a previous run and would never change. For the same reason, it exits with an error
+// if the session file lists such statuses but has not been updated for boone.SessionFileStaleAfter.
+//
+// It exits with code 0 if no target is failing, 1 if at least one target is failing, 2 if the status
+// list could not be read or the sub-command was interrupted, and 3 if the timeout was reached first.
+//
+// Usage:
+//
+//	boone wait --config /path/to/config
+//	boone wait --config /path/to/config --target some_id --target other_id --timeout 10m
+package wait
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/codeactual/boone/internal/boone"
+	"github.com/codeactual/boone/internal/cage/cli/handler"
+	handler_cobra "github.com/codeactual/boone/internal/cage/cli/handler/cobra"
+)
+
+const (
+	// ExitFailing is the exit code used when at least one target is failing.
+	ExitFailing = 1
+
+	// ExitError is the exit code used when the status list could not be read or waiting was interrupted.
+	ExitError = 2
+
+	// ExitTimeout is the exit code used when targets are still in progress after the timeout.
+	ExitTimeout = 3
+
+	// PollInterval is how often the status list is read.
+	//
+	// It is longer than boone.PreDebounce so that activity which occurred just before the sub-command
+	// started has time to reach the Dispatcher before the first idle poll.
+	PollInterval = time.Second
+
+	// IdlePolls is how many consecutive polls must observe no debouncing/pending/running target.
+	IdlePolls = 2
+)
+
+// Handler defines the sub-command flags and logic.
+type Handler struct {
+	handler.Session
+
+	ConfigPath string
+
+	// TargetId optionally limits which targets are considered.
+	TargetId []string
+
+	// Timeout is a time.Duration compatible string. If empty, the sub-command waits indefinitely.
+	Timeout string
+}
+
+// Init defines the command, its environment variable prefix, etc.
+//
+// It implements cli/handler/cobra.Handler.
+func (h *Handler) Init() handler_cobra.Init {
+	return handler_cobra.Init{
+		Cmd: &cobra.Command{
+			Use:   "wait",
+			Short: "Wait until no target is debouncing, pending, or running",
+			Example: strings.Join([]string{
+				"boone wait --config /path/to/config",
+				"boone wait --config /path/to/config --target some_id --target other_id --timeout 10m",
+			}, "\n"),
+		},
+		EnvPrefix: "BOONE",
+	}
+}
+
+// BindFlags binds the flags to Handler fields.
+//
+// It implements cli/handler/cobra.Handler.
+func (h *Handler) BindFlags(cmd *cobra.Command) []string {
+	cmd.Flags().StringVarP(&h.ConfigPath, "config", "c", "", "viper-readable config file")
+	cmd.Flags().StringSliceVarP(&h.TargetId, "target", "t", []string{}, "only consider this Target.Id (repeatable)")
+	cmd.Flags().StringVarP(&h.Timeout, "timeout", "", "", "give up after this time.Duration, e.g. 10m")
+	return []string{"config"}
+}
+
+// Run performs the sub-command logic.
+//
+// It implements cli/handler/cobra.Handler.
+func (h *Handler) Run(ctx context.Context, input handler.Input) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Report the interruption instead of exiting with the signal's default behavior.
+	stopOnSignal := func(os.Signal) { cancel() }
+	h.OnSignal(syscall.SIGTERM, stopOnSignal)
+	h.OnSignal(syscall.SIGINT, stopOnSignal)
+
+	summary, timedOut, err := h.run(ctx)
+	h.ExitOnErrShort(err, "failed to wait for targets", ExitError)
+
+	if timedOut {
+		fmt.Fprintf(h.Err(), "timed out after %s\n", h.Timeout)
+		writeList(h.Err(), "Pending", summary.Pending)
+		writeList(h.Err(), "Running", summary.Running)
+		writeList(h.Err(), "Failing", summary.Failing)
+		os.Exit(ExitTimeout)
+	}
+
+	writeList(h.Err(), "Flaky", summary.Flaky)
+
+	if len(summary.Failing) > 0 {
+		writeList(h.Err(), "Failing", summary.Failing)
+		os.Exit(ExitFailing)
+	}
+}
+
+func (h *Handler) run(ctx context.Context) (summary boone.StatusSummary, timedOut bool, err error) {
+	cfg, err := boone.ReadConfigFile(h.ConfigPath)
+	if err != nil {
+		return boone.StatusSummary{}, false, errors.Wrapf(err, "failed to read config file [%s]", h.ConfigPath)
+	}
+
+	for _, id := range h.TargetId {
+		var found bool
+		for _, t := range cfg.Target {
+			if t.Id == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return boone.StatusSummary{}, false, errors.Errorf("target with Id [%s] not found", id)
+		}
+	}
+
+	if h.Timeout != "" {
+		timeout, parseErr := time.ParseDuration(h.Timeout)
+		if parseErr != nil {
+			return boone.StatusSummary{}, false, errors.Wrapf(parseErr, "failed to parse timeout [%s]", h.Timeout)
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	ticker := time.NewTicker(PollInterval)
+	defer ticker.Stop()
+
+	var idle int
+	for {
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return summary, true, nil
+			}
+			return boone.StatusSummary{}, false, errors.Wrap(ctx.Err(), "stopped waiting")
+		case <-ticker.C:
+			session, src, readErr := boone.ReadSession(cfg)
+			if readErr != nil {
+				return boone.StatusSummary{}, false, errors.WithStack(readErr)
+			}
+			if src == boone.SessionFromFile && cfg.Data.Control.File != "" {
+				return boone.StatusSummary{}, false, errors.Errorf(
+					"no running instance accepts connections on [Data.Control.File] [%s]", cfg.Data.Control.File,
+				)
+			}
+			if src == boone.SessionFromFile {
+				if staleErr := boone.CheckSessionFile(cfg.Data.Session.File, session); staleErr != nil {
+					return boone.StatusSummary{}, false, errors.WithStack(staleErr)
+				}
+			}
+
+			summary = h.filter(session).Summary()
+
+			if summary.Idle() {
+				idle++
+			} else {
+				idle = 0
+			}
+			if idle >= IdlePolls {
+				return summary, false, nil
+			}
+		}
+	}
+}
+
+// filter returns the session with only the statuses of targets selected by --target, or the whole
+// session if none were selected.
+func (h *Handler) filter(session boone.Session) boone.Session {
+	if len(h.TargetId) == 0 {
+		return session
+	}
+	selected := func(statuses []boone.Status) (filtered []boone.Status) {
+		for _, status := range statuses {
+			for _, id := range h.TargetId {
+				if status.TargetId == id {
+					filtered = append(filtered, status)
+					break
+				}
+			}
+		}
+		return filtered
+	}
+	session.Statuses = selected(session.Statuses)
+	session.Flaky = selected(session.Flaky)
+	return session
+}
+
+func writeList(w io.Writer, title string, statuses []boone.Status) {
+	if len(statuses) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%s (%d)\n", title, len(statuses))
+	for _, status := range statuses {
+		if status.HandlerLabel == "" {
+			fmt.Fprintf(w, "  - %s | %s\n", status.TargetLabel, status.Cause)
+		} else {
+			fmt.Fprintf(w, "  - %s | %s | %s\n", status.TargetLabel, status.HandlerLabel, status.Cause)
+		}
+		if status.Err != "" {
+			fmt.Fprintf(w, "    %s\n", status.Err)
+		}
+		if len(status.Flaky) > 0 {
+			fmt.Fprintf(w, "    %s\n", strings.Join(status.Flaky, ", "))
+		}
+	}
+}
+
+// New returns a cobra command instance based on Handler.
+func NewCommand() *cobra.Command {
+	return handler_cobra.NewHandler(&Handler{
+		Session: &handler.DefaultSession{},
+	})
+}
+
+var _ handler_cobra.Handler = (*Handler)(nil)
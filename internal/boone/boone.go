@@ -8,11 +8,28 @@
 package boone
 
 import (
+	"os"
+	"regexp"
+	"strings"
 	"time"
 
+	"github.com/pkg/errors"
+
 	cage_filepath "github.com/codeactual/boone/internal/cage/path/filepath"
+	cage_shell "github.com/codeactual/boone/internal/cage/shell"
+	cage_structs "github.com/codeactual/boone/internal/cage/structs"
+	cage_template "github.com/codeactual/boone/internal/cage/text/template"
 )
 
+// ColorForceEnv holds the "KEY=VALUE" pairs added to the environment of commands configured with
+// Exec.Color "force".
+var ColorForceEnv = []string{
+	"CLICOLOR=1",
+	"CLICOLOR_FORCE=1",
+	"FORCE_COLOR=1",
+	"PY_COLORS=1",
+}
+
 // TargetStatus explains why a target is listed in the UI on the initial screen.
 type TargetStatus string
 
@@ -24,26 +41,69 @@ const (
 	// duplicates and less than user-selected per-Target debounce values.
 	PreDebounce = 500 * time.Millisecond
 
+	// ExecColorForce is the Exec.Color value which makes commands emit ANSI colors.
+	ExecColorForce = "force"
+
+	// ExecFormatGoTestJSON is the Exec.Format value of commands which print "go test -json" output.
+	ExecFormatGoTestJSON = "gotest-json"
+
+	// OnFailureContinue is the Target.OnFailure value which runs all remaining targets in the tree,
+	// including those which depend on the failed target.
+	OnFailureContinue = "continue"
+
+	// OnFailureSkipDependents is the Target.OnFailure value which skips the remaining targets in the tree
+	// which depend on the failed target, directly or transitively, and runs all others.
+	OnFailureSkipDependents = "skip-dependents"
+
+	// OnFailureStopTree is the Target.OnFailure value which ends the tree run at the failed target.
+	OnFailureStopTree = "stop-tree"
+
 	// SessionVersion is included in the encoded Session file to support potential compatibility work.
 	SessionVersion = 1
 
 	// Target
 	TargetCanceled TargetStatus = "canceled"
 
+	// TargetDebouncing indicates the target received file activity and the Dispatcher is waiting for
+	// the activity to stop, for Target.Debounce amount of time, before enqueuing it.
+	TargetDebouncing TargetStatus = "debouncing"
+
 	// TargetFailed indicates a target command returned a non-zero exit code and the Dispatcher
 	// will not proceed any further with that target until it is activated again.
 	TargetFailed TargetStatus = "failed"
 
+	// TargetPassed indicates all of a target's commands finished successfully.
+	//
+	// It only describes a target's last result, e.g. in the target browser and Session.Flaky, because
+	// passing targets are removed from the status list.
+	TargetPassed TargetStatus = "passed"
+
 	// TargetPending indicates the target's latest file activity has been debounced, the target
 	// has been enqueued to run, and it is waiting to start.
+	//
+	// It also indicates a downstream target waiting for the upstream targets of a tree run, in which
+	// case Status.UpstreamTargetLabel names the target which started the run.
 	TargetPending TargetStatus = "pending"
 
 	// TargetResumed indicates the program saved a TargetStarted-status target in its session file
 	// (if configured) at shutdown, then enqueued it during startup.
 	TargetResumed TargetStatus = "resumed"
 
+	// TargetSkipped indicates the Dispatcher did not run the target because an upstream target in the
+	// same tree failed and its Target.OnFailure is OnFailureSkipDependents, or the tree run stopped
+	// before reaching the target.
+	TargetSkipped TargetStatus = "skipped"
+
 	// TargetStarted indicates a Dispatcher has started running the target's command(s).
 	TargetStarted TargetStatus = "started"
+
+	// TargetTimedOut indicates a target command was stopped because its Exec.Timeout, its
+	// Target.Timeout, or the GlobalConfig.TreeTimeout of the run was reached.
+	TargetTimedOut TargetStatus = "timed-out"
+
+	// TargetWarned indicates a command of a Handler.AllowFailure handler failed, but the Dispatcher
+	// continued with the target's other handlers and its downstream targets, all of which passed.
+	TargetWarned TargetStatus = "warned"
 )
 
 // Handler defines one or more commands that must execute in response to a target trigger.
@@ -56,6 +116,32 @@ type Handler struct {
 
 	// Exec defines the commands to execute.
 	Exec []Exec
+
+	// AllowFailure is true if a failed command should only produce a TargetWarned status.
+	//
+	// The handler's remaining commands are skipped, but the target's other handlers and its downstream
+	// targets still run.
+	AllowFailure bool
+
+	// If optionally defines conditions, e.g. which file changed, which must be met for the handler to run.
+	If HandlerIf
+
+	// Parallel is true if the handler may run at the same time as the Parallel handlers declared directly
+	// before it, instead of waiting for them to finish.
+	//
+	// It still waits for all other earlier handlers. It is ignored if Needs is configured.
+	Parallel bool
+
+	// Needs optionally holds the labels of handlers, declared earlier in the same target, which must finish
+	// before this one starts. The handler does not wait for any other handler.
+	//
+	// The handler does not run if one of them fails or is canceled.
+	Needs []string
+
+	// deps holds the indexes of the target's handlers which must finish before this one starts.
+	//
+	// It is generated at startup from Parallel and Needs.
+	deps []int
 }
 
 // Exec defines what command to run and how to run it.
@@ -73,10 +159,79 @@ type Exec struct {
 	Timeout string
 
 	// Env holds "KEY=VALUE" pairs to overwrite in the current environment.
+	//
+	// They are also visible to $VAR expansion in Cmd. See Environ for the precedence of each source.
 	Env []string
 
+	// EnvFile holds paths to .env-style files, relative to Dir, whose "KEY=VALUE" pairs are added to
+	// the environment before Env. They are read before each run.
+	EnvFile []string
+
+	// Color optionally selects how the command should decide whether to emit ANSI colors.
+	//
+	// "force" sets variables such as FORCE_COLOR and CLICOLOR_FORCE, which many tools check before falling
+	// back to disabling colors because their output is not a terminal. Values from any Env or EnvFile,
+	// including those of GlobalConfig and Target, take precedence.
+	//
+	// If empty, the environment is not modified.
+	Color string
+
+	// ProblemMatcher optionally defines how to extract diagnostics, e.g. compiler errors, from the output
+	// of a failed command.
+	//
+	// Each Preset is expanded into one item per built-in pattern at startup.
+	ProblemMatcher []ProblemMatcher
+
+	// Shell optionally selects an interpreter, e.g. "bash -c", which receives the expanded Cmd as its
+	// final argument instead of Cmd being parsed into a "|" pipeline.
+	//
+	// It enables shell syntax such as "&&", ";", redirects, globs, and "$(...)". The interpreter runs
+	// in its own process group, like other commands, so cancellation also kills its child processes.
+	Shell string
+
+	// Format optionally selects how to interpret standard output.
+	//
+	// ExecFormatGoTestJSON records the result of each test, from "go test -json" output, and displays
+	// the output as "go test" would have printed it without -json.
+	Format string
+
+	// SuccessCodes optionally lists the exit codes which indicate the command passed, e.g. [0, 1] for a tool
+	// which exits with 1 if it found changes to make.
+	//
+	// If empty, only 0 indicates a pass. See Evaluate for how it is combined with FailOnOutput and PassOnOutput.
+	SuccessCodes []int
+
+	// FailOnOutput optionally defines a regular expression which fails the command if it matches standard
+	// output or error, e.g. for a linter which exits with 0 even though it printed findings.
+	FailOnOutput string
+
+	// PassOnOutput optionally defines a regular expression which passes the command if it matches standard
+	// output or error, regardless of the exit code, unless FailOnOutput also matches.
+	PassOnOutput string
+
+	// Retry optionally runs the command again, within the same target run, if it fails.
+	//
+	// Retries stop if file activity cancels the tree. The last attempt's result is reported.
+	Retry Retry
+
 	// timeout is the parsed version of Timeout.
 	timeout time.Duration
+
+	// shell is the parsed version of Shell.
+	shell []string
+
+	// failOnOutput is the compiled version of FailOnOutput.
+	failOnOutput *regexp.Regexp
+
+	// passOnOutput is the compiled version of PassOnOutput.
+	passOnOutput *regexp.Regexp
+
+	// inheritedEnv holds the environment configuration of GlobalConfig and Target, in that order.
+	inheritedEnv []envSource
+
+	// template is a copy of the Template config section, whose variables are expanded in Cmd along
+	// with CmdTemplateData.
+	template map[string]string
 }
 
 // GetTimeout returns the parsed value of Timeout.
@@ -84,6 +239,106 @@ func (e Exec) GetTimeout() time.Duration {
 	return e.timeout
 }
 
+// CmdArgs returns the arguments of each command in the pipeline which runs the expanded Cmd.
+//
+// If Shell is empty, the pipeline is parsed from the expanded Cmd and its $VAR references are expanded
+// from the input environment, e.g. from Environ. Otherwise the pipeline is the one interpreter command.
+func (e Exec) CmdArgs(cmdExpanded string, env []string) ([][]string, error) {
+	if len(e.shell) > 0 {
+		return [][]string{append(append([]string{}, e.shell...), cmdExpanded)}, nil
+	}
+	args, err := cage_shell.ParseWithEnv(cmdExpanded, EnvLookup(env))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return args, nil
+}
+
+// ExpandCmd returns Cmd after expanding its template variables and functions.
+//
+// The env template function finds values in the "KEY=VALUE" pairs, e.g. from Environ, so it reads
+// the same environment as the command.
+func (e Exec) ExpandCmd(data CmdTemplateData, env []string) (string, error) {
+	merged := cage_structs.MergeAsStringMap(cage_structs.MergeModeOverwrite, e.template, data)
+	buf, err := cage_template.ExecuteBufferedFuncs(e.Cmd, merged, cage_template.FuncsWithEnv(EnvLookup(env)))
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to expand command [%s]", e.Cmd)
+	}
+	return buf.String(), nil
+}
+
+// Environ returns the "KEY=VALUE" pairs of the command's environment, which are also used to
+// expand $VAR references in Cmd.
+//
+// Sources in ascending precedence: the boone process environment, ColorEnv, GlobalConfig.EnvFile,
+// GlobalConfig.Env, Target.EnvFile, Target.Env, EnvFile, and Env.
+func (e Exec) Environ() ([]string, error) {
+	lists := [][]string{os.Environ(), e.ColorEnv()}
+	for _, s := range e.inheritedEnv {
+		pairs, err := s.read()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		lists = append(lists, pairs)
+	}
+
+	pairs, err := envSource{env: e.Env, envFile: e.EnvFile}.read()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return MergeEnv(append(lists, pairs)...), nil
+}
+
+// MergeEnv returns the "KEY=VALUE" pairs of all lists, where a key's value from a later list
+// overwrites the value from an earlier one.
+//
+// Keys are ordered by their first occurrence. Pairs without a "=" are treated as empty values.
+func MergeEnv(lists ...[]string) []string {
+	var keys []string
+	values := map[string]string{}
+	for _, list := range lists {
+		for _, pair := range list {
+			kv := strings.SplitN(pair, "=", 2)
+			if _, ok := values[kv[0]]; !ok {
+				keys = append(keys, kv[0])
+			}
+			if len(kv) == 2 {
+				values[kv[0]] = kv[1]
+			} else {
+				values[kv[0]] = ""
+			}
+		}
+	}
+
+	env := make([]string, 0, len(keys))
+	for _, k := range keys {
+		env = append(env, k+"="+values[k])
+	}
+	return env
+}
+
+// EnvLookup returns a function which finds a key's value in "KEY=VALUE" pairs, where a later pair
+// overwrites an earlier one, or an empty string if the key is not found.
+func EnvLookup(env []string) func(string) string {
+	values := map[string]string{}
+	for _, pair := range MergeEnv(env) {
+		kv := strings.SplitN(pair, "=", 2)
+		values[kv[0]] = kv[1]
+	}
+	return func(key string) string {
+		return values[key]
+	}
+}
+
+// ColorEnv returns the "KEY=VALUE" pairs implied by Color.
+func (e Exec) ColorEnv() []string {
+	if e.Color == ExecColorForce {
+		return append([]string{}, ColorForceEnv...)
+	}
+	return []string{}
+}
+
 // Status describes a target listed in the UI on its initial screen.
 type Status struct {
 	// Cause explains why the status is in the list.
@@ -92,6 +347,18 @@ type Status struct {
 	// Cmd was the final command string after template expansion.
 	Cmd string
 
+	// Attempt is how many times Cmd has run, including the current run, out of Attempts.
+	Attempt int
+
+	// Attempts is the maximum number of times Cmd may run based on Exec.Retry.
+	Attempts int
+
+	// Diagnostics holds the problems which Exec.ProblemMatcher found in Stderr and Stdout.
+	Diagnostics []Diagnostic
+
+	// Dir is the working directory of Cmd, used to resolve relative file locations in its output.
+	Dir string
+
 	// Downstream holds labels of all downstream targets included in the run.
 	Downstream []string
 
@@ -121,6 +388,19 @@ type Status struct {
 	// RunLen is how long Cmd ran.
 	RunLen time.Duration
 
+	// SkippedHandler holds one description, e.g. "codegen: op [Write] is not one of If.Op [Create]",
+	// per handler of the target which was skipped because its Handler.If was not met.
+	SkippedHandler []string
+
+	// Failures holds the TargetFailed/TargetCanceled/TargetTimedOut statuses of the target's other handlers
+	// which did not pass in the same run, e.g. Handler.Parallel handlers, in declared order.
+	//
+	// It is only populated in the status of the target's first failed handler.
+	Failures []Status
+
+	// Flaky is a copy of TargetPass.Flaky if Cause is TargetPassed.
+	Flaky []string
+
 	// StartTime is when Cmd started.
 	StartTime time.Time
 
@@ -136,19 +416,49 @@ type Status struct {
 	// TargetLabel is from the source of the status.
 	TargetLabel string
 
+	// Tests holds the results parsed from the output of Exec.Format ExecFormatGoTestJSON commands.
+	Tests []TestResult
+
 	// UpstreamTargetLabel is the one whose activity triggered the handler execution flow
 	// that may include one or more (of its) downstream targets. If there were no
 	// downstream targets, it should equal the Target field.
 	UpstreamTargetLabel string
 }
 
-// TargetPass describes a target whose commands all finished successfully.
+// Rerunnable returns true if the status is the finished, non-passing result of a run, e.g. failed or
+// warned, which the UI can run again.
+func (s Status) Rerunnable() bool {
+	switch s.Cause {
+	case TargetFailed, TargetCanceled, TargetTimedOut, TargetSkipped, TargetWarned:
+		return true
+	}
+	return false
+}
+
+// awaitingActivity returns true if the status is debouncing or pending because of the target's own
+// file activity, rather than waiting for its upstream targets in a tree run.
+func (s Status) awaitingActivity() bool {
+	return s.Cause == TargetDebouncing || (s.Cause == TargetPending && s.UpstreamTargetLabel == "")
+}
+
+// TargetPass describes a target whose commands all finished successfully, except for any in
+// Handler.AllowFailure handlers.
 type TargetPass struct {
+	// Flaky holds one description, e.g. "lint passed on attempt 2/3", per command which only passed
+	// after Exec.Retry ran it again.
+	Flaky []string
+
 	// RunLen is how long it took to run a target's command list.
 	RunLen time.Duration
 
+	// SkippedHandler holds one description per handler which was skipped because its Handler.If was not met.
+	SkippedHandler []string
+
 	// TargetId is a copy of Target.Id.
 	TargetId string
+
+	// Warned holds one TargetWarned status per Handler.AllowFailure handler which failed.
+	Warned []Status
 }
 
 // TreePass describes a set of targets (activity-triggered target and all its downstream targets) whose commands
@@ -165,15 +475,33 @@ type Session struct {
 	// created.
 	Statuses []Status
 
+	// Flaky holds one TargetPassed status, with Status.Flaky, per target whose last run only passed
+	// after Exec.Retry ran a command again.
+	Flaky []Status
+
 	// Version is a copy of the SessionVersion constant when the Session value is created.
 	Version int
 }
 
 // CmdTemplateData describes the built-in template variables available in Target.Handler.Exec.Cmd config strings.
+//
+// They are available along with the Template config section's variables and the cage/text/template functions.
 type CmdTemplateData struct {
+	// AffectedPackages holds the space-separated import paths of the Packages value and every package,
+	// under Target.Root, which depends on it directly or transitively, including through its tests.
+	//
+	// It is only available if Target.Go is enabled.
+	AffectedPackages string
+
 	// Dir is the absolute path of the parent directory of Path.
 	Dir string
 
+	// FailedTests is a "go test -run" pattern which matches the top-level tests that failed during the
+	// target's last Exec.Format ExecFormatGoTestJSON command, e.g. "^(TestA|TestB)$".
+	//
+	// It is empty if no test failed or the target has not run such a command yet.
+	FailedTests string
+
 	// HandlerLabel is a copy of Target.Handler.Label.
 	HandlerLabel string
 
@@ -184,9 +512,35 @@ type CmdTemplateData struct {
 	// command being triggered.
 	IncludeRoot string
 
+	// Op is the file activity on Path, e.g. "Write" or "Create".
+	//
+	// It is empty if Path is empty.
+	Op string
+
+	// Packages holds the import path of the package which contains Path, or GoAllPackages if Path
+	// is not a .go file of a package under Target.Root.
+	//
+	// It is only available if Target.Go is enabled.
+	Packages string
+
 	// Path is the absolute path of the file/directory that was created or written to.
 	Path string
 
+	// RelPath is Path relative to Root.
+	//
+	// It is empty if Path is empty.
+	RelPath string
+
+	// Root is a copy of Target.Root.
+	Root string
+
+	// RunId identifies the run of the target tree which executes the command. It is shared by all commands
+	// of the run, e.g. to name a directory of artifacts.
+	RunId string
+
+	// TargetId is a copy of Target.Id.
+	TargetId string
+
 	// TargetLabel is a copy of Target.Label.
 	TargetLabel string
 }
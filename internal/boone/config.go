@@ -8,6 +8,7 @@ package boone
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 	"time"
@@ -19,6 +20,7 @@ import (
 	cage_io "github.com/codeactual/boone/internal/cage/io"
 	cage_file "github.com/codeactual/boone/internal/cage/os/file"
 	cage_filepath "github.com/codeactual/boone/internal/cage/path/filepath"
+	cage_shell "github.com/codeactual/boone/internal/cage/shell"
 	cage_structs "github.com/codeactual/boone/internal/cage/structs"
 	cage_template "github.com/codeactual/boone/internal/cage/text/template"
 )
@@ -33,6 +35,9 @@ const (
 	// DefaultCooldown is the default Global value.
 	DefaultCooldown = "5s"
 
+	// DefaultOnFailure is the default Global.OnFailure value.
+	DefaultOnFailure = OnFailureStopTree
+
 	// dataDirPerm is the default permissions granted for new directories.
 	dataDirPerm = 0700
 
@@ -47,10 +52,38 @@ type SessionConfig struct {
 	File string
 }
 
+// ControlConfig defines where a running instance answers requests from other processes,
+// e.g. the "status" sub-command.
+//
+// Its config section is Data.Control.
+type ControlConfig struct {
+	// File is the Unix socket path.
+	File string
+}
+
+// QuickfixConfig defines where to list current failures for editors such as vim.
+//
+// Its config section is Data.Quickfix.
+type QuickfixConfig struct {
+	// File is rewritten whenever the status list changes.
+	File string
+
+	// Format is QuickfixFormatEntries or QuickfixFormatOutput.
+	//
+	// It defaults to DefaultQuickfixFormat.
+	Format string
+}
+
 // DataConfig defines how to store program state.
 //
 // Its config section is Data.
 type DataConfig struct {
+	// Control defines where a running instance answers requests from other processes.
+	Control ControlConfig
+
+	// Quickfix defines where to list current failures for editors such as vim.
+	Quickfix QuickfixConfig
+
 	// Session defines how to store sessions.
 	Session SessionConfig
 }
@@ -78,6 +111,9 @@ type Config struct {
 
 	// startTarget is computed and populated by AutoStartTarget selections.
 	startTarget []Target
+
+	// dir is the absolute path of the directory which holds the config file, if it was read from one.
+	dir string
 }
 
 // GetStartTarget returns all targets selected in the AutoStartTarget config.
@@ -93,11 +129,42 @@ type GlobalConfig struct {
 	// finishes before starting another.
 	Cooldown string
 
+	// Editor is a command template which opens a file location selected in the UI.
+	//
+	// It supports the EditorTemplateData fields, e.g. "code -g {{.File}}:{{.Line}}:{{.Column}}",
+	// and defaults to DefaultEditor.
+	Editor string
+
+	// Env holds "KEY=VALUE" pairs added to the environment of all commands.
+	//
+	// They are overwritten by Target.Env and Exec.Env.
+	Env []string
+
+	// EnvFile holds paths to .env-style files, relative to the directory of the config file, whose
+	// "KEY=VALUE" pairs are added to the environment of all commands before Env. They are read before each run.
+	//
+	// If the config was not read from a file, relative paths are resolved from the working directory.
+	EnvFile []string
+
 	// Exclude are appended to every Target.Exclude list.
 	Exclude []cage_filepath.Glob
 
+	// OnFailure is the default Target.OnFailure value.
+	//
+	// It defaults to DefaultOnFailure.
+	OnFailure string
+
+	// TreeTimeout is a time.Duration compatible string which limits how long a run of a triggered target
+	// and all its downstream targets may take.
+	//
+	// If empty, runs are only limited by Target.Timeout and Exec.Timeout.
+	TreeTimeout string
+
 	// cooldown is converted from Cooldown.
 	cooldown time.Duration
+
+	// treeTimeout is converted from TreeTimeout.
+	treeTimeout time.Duration
 }
 
 // GetCooldown returns the converted value of Cooldown.
@@ -105,6 +172,11 @@ func (c GlobalConfig) GetCooldown() time.Duration {
 	return c.cooldown
 }
 
+// GetTreeTimeout returns the converted value of TreeTimeout, or 0 if it is empty.
+func (c GlobalConfig) GetTreeTimeout() time.Duration {
+	return c.treeTimeout
+}
+
 // ReadConfigFile converts a file to a Config value.
 func ReadConfigFile(name string) (c Config, err error) {
 	file := std_viper.New()
@@ -120,6 +192,12 @@ func ReadConfigFile(name string) (c Config, err error) {
 		return Config{}, errors.Wrapf(err, "failed to unmarshal config from file [%s]", name)
 	}
 
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return Config{}, errors.Wrapf(err, "failed to get absolute path of config file [%s]", name)
+	}
+	c.dir = filepath.Dir(abs)
+
 	allTargets := []*Target{}
 	for k := range c.Target {
 		allTargets = append(allTargets, &c.Target[k])
@@ -148,6 +226,20 @@ func FinalizeConfig(all []*Target, c *Config) error {
 		defer cage_io.CloseOrStderr(f, c.Data.Session.File)
 	}
 
+	if c.Data.Quickfix.File != "" {
+		f, err := cage_file.CreateFileAll(c.Data.Quickfix.File, 0, dataFilePerm, dataDirPerm)
+		if err != nil {
+			return errors.Wrapf(err, "failed to init quickfix file [%s]", c.Data.Quickfix.File)
+		}
+		defer cage_io.CloseOrStderr(f, c.Data.Quickfix.File)
+	}
+	if c.Data.Quickfix.Format == "" {
+		c.Data.Quickfix.Format = DefaultQuickfixFormat
+	}
+	if c.Data.Quickfix.Format != QuickfixFormatEntries && c.Data.Quickfix.Format != QuickfixFormatOutput {
+		return errors.Errorf("Data.Quickfix.Format [%s] is not supported", c.Data.Quickfix.Format)
+	}
+
 	if c.Global.Cooldown == "" {
 		c.Global.Cooldown = DefaultCooldown
 	}
@@ -157,6 +249,39 @@ func FinalizeConfig(all []*Target, c *Config) error {
 		return errors.Wrapf(cooldownErr, "failed to parse Cooldown [%s]", c.Global.Cooldown)
 	}
 
+	if c.Global.TreeTimeout != "" {
+		var treeTimeoutErr error
+		c.Global.treeTimeout, treeTimeoutErr = time.ParseDuration(c.Global.TreeTimeout)
+		if treeTimeoutErr != nil {
+			return errors.Wrapf(treeTimeoutErr, "failed to parse TreeTimeout [%s]", c.Global.TreeTimeout)
+		}
+	}
+
+	if c.Global.Editor != "" {
+		if _, editorErr := cage_template.ExecuteBuffered(c.Global.Editor, EditorTemplateData{}); editorErr != nil {
+			return errors.Wrapf(editorErr, "failed to parse Editor [%s]", c.Global.Editor)
+		}
+	}
+
+	if c.Global.OnFailure == "" {
+		c.Global.OnFailure = DefaultOnFailure
+	}
+	if !validOnFailure(c.Global.OnFailure) {
+		return errors.Errorf("Global.OnFailure [%s] is not supported", c.Global.OnFailure)
+	}
+
+	// Resolve from the config file's directory so every process which reads the config, e.g. from
+	// subcommands started in other directories, finds the same files.
+	envFileDir := c.dir
+	if envFileDir == "" {
+		wd, wdErr := os.Getwd()
+		if wdErr != nil {
+			return errors.Wrap(wdErr, "failed to get working directory to resolve Global.EnvFile paths")
+		}
+		envFileDir = wd
+	}
+	c.Global.EnvFile = resolveEnvFiles(envFileDir, c.Global.EnvFile)
+
 	var expectedTemplateKeys []string
 	for k := range c.Template {
 		expectedTemplateKeys = append(expectedTemplateKeys, k)
@@ -214,6 +339,13 @@ func FinalizeConfig(all []*Target, c *Config) error {
 		}
 		uniqueLabel[t.Label] = true
 
+		if t.OnFailure == "" {
+			t.OnFailure = c.Global.OnFailure
+		}
+		if !validOnFailure(t.OnFailure) {
+			return errors.Errorf("target [%s] OnFailure [%s] is not supported", t.Label, t.OnFailure)
+		}
+
 		if t.Debounce == "" {
 			t.Debounce = DefaultDebounce
 		}
@@ -223,6 +355,14 @@ func FinalizeConfig(all []*Target, c *Config) error {
 			return errors.Wrapf(debounceErr, "[target: %s]: failed to parse Debounce [%s]", t.Label, t.Debounce)
 		}
 
+		if t.Timeout != "" {
+			var timeoutErr error
+			t.timeout, timeoutErr = time.ParseDuration(t.Timeout)
+			if timeoutErr != nil {
+				return errors.Wrapf(timeoutErr, "[target: %s]: failed to parse Timeout [%s]", t.Label, t.Timeout)
+			}
+		}
+
 		// Default all per-include roots to the target root.
 		// Resolve all per-include roots as relative to the target root.
 		// Resolve all globs as relative to the per-include root.
@@ -337,6 +477,12 @@ func FinalizeConfig(all []*Target, c *Config) error {
 	for n := range all { // perform in 2nd pass so Id/Label/etc are already finalized
 		t := all[n]
 
+		t.EnvFile = resolveEnvFiles(t.Root, t.EnvFile)
+		inheritedEnv := []envSource{
+			{env: c.Global.Env, envFile: c.Global.EnvFile},
+			{env: t.Env, envFile: t.EnvFile},
+		}
+
 		// Exec.Dir values must be relative to Target.Root and default to Target.Root
 		for h, handler := range t.Handler {
 			for e, exe := range handler.Exec {
@@ -361,16 +507,66 @@ func FinalizeConfig(all []*Target, c *Config) error {
 					}
 				}
 
+				t.Handler[h].Exec[e].EnvFile = resolveEnvFiles(t.Handler[h].Exec[e].Dir, exe.EnvFile)
+				t.Handler[h].Exec[e].inheritedEnv = inheritedEnv
+
 				if t.Handler[h].Exec[e].Timeout == "" {
 					t.Handler[h].Exec[e].Timeout = DefaultCmdTimeout
 				}
 
+				if exe.Color != "" && exe.Color != ExecColorForce {
+					return errors.Errorf("[target: %s]: handler [%s] command [%s] has an unsupported Color [%s]", t.Label, handler.Label, exe.Cmd, exe.Color)
+				}
+
+				t.Handler[h].Exec[e].template = c.Template
+				if _, cmdErr := t.Handler[h].Exec[e].ExpandCmd(CmdTemplateData{}, nil); cmdErr != nil {
+					return errors.Wrapf(cmdErr, "[target: %s]: handler [%s] has an invalid Cmd template", t.Label, handler.Label)
+				}
+
+				if exe.Shell != "" {
+					shellArgs, shellErr := cage_shell.Parse(exe.Shell)
+					if shellErr != nil {
+						return errors.Wrapf(shellErr, "[target: %s]: handler [%s] command [%s] has an invalid Shell [%s]", t.Label, handler.Label, exe.Cmd, exe.Shell)
+					}
+					if len(shellArgs) != 1 {
+						return errors.Errorf("[target: %s]: handler [%s] command [%s] Shell [%s] must be one command without pipes", t.Label, handler.Label, exe.Cmd, exe.Shell)
+					}
+					t.Handler[h].Exec[e].shell = shellArgs[0]
+				}
+
+				if exe.Format != "" && exe.Format != ExecFormatGoTestJSON {
+					return errors.Errorf("[target: %s]: handler [%s] command [%s] has an unsupported Format [%s]", t.Label, handler.Label, exe.Cmd, exe.Format)
+				}
+
+				var matcherErr error
+				t.Handler[h].Exec[e].ProblemMatcher, matcherErr = CompileProblemMatchers(exe.ProblemMatcher)
+				if matcherErr != nil {
+					return errors.Wrapf(matcherErr, "[target: %s]: handler [%s] command [%s] has an invalid ProblemMatcher", t.Label, handler.Label, exe.Cmd)
+				}
+
+				if successErr := finalizeSuccess(&t.Handler[h].Exec[e]); successErr != nil {
+					return errors.Wrapf(successErr, "[target: %s]: handler [%s] command [%s] has invalid success criteria", t.Label, handler.Label, exe.Cmd)
+				}
+
+				if retryErr := finalizeRetry(&t.Handler[h].Exec[e].Retry); retryErr != nil {
+					return errors.Wrapf(retryErr, "[target: %s]: handler [%s] command [%s] has an invalid Retry", t.Label, handler.Label, exe.Cmd)
+				}
+
 				var timeoutErr error
 				t.Handler[h].Exec[e].timeout, timeoutErr = time.ParseDuration(t.Handler[h].Exec[e].Timeout)
 				if timeoutErr != nil {
 					return errors.Wrapf(timeoutErr, "[target: %s]: failed to parse handler [%s] command [%s] Timeout [%s]", t.Label, t.Handler[h].Label, t.Handler[h].Exec[e].Cmd, t.Handler[h].Exec[e].Timeout)
 				}
 			}
+
+			when := Exec{inheritedEnv: inheritedEnv, template: c.Template}
+			if ifErr := finalizeHandlerIf(t, &t.Handler[h].If, when); ifErr != nil {
+				return errors.Wrapf(ifErr, "[target: %s]: handler [%s] has an invalid If", t.Label, handler.Label)
+			}
+		}
+
+		if depsErr := finalizeHandlerDeps(t); depsErr != nil {
+			return errors.Wrapf(depsErr, "[target: %s]", t.Label)
 		}
 	}
 
@@ -412,18 +608,28 @@ func FinalizeConfig(all []*Target, c *Config) error {
 
 		t.Tree = []TargetTree{
 			{
-				Id:      t.Id,
-				Label:   t.Label,
-				Handler: append([]Handler{}, t.Handler...),
+				Id:        t.Id,
+				Label:     t.Label,
+				Root:      t.Root,
+				Handler:   append([]Handler{}, t.Handler...),
+				OnFailure: t.OnFailure,
+				Timeout:   t.Timeout,
+				Upstream:  t.Upstream,
+				timeout:   t.timeout,
 			},
 		}
 		visitErr := VisitDownstream(t, func(target *Target) error {
 			t.Tree = append(
 				t.Tree,
 				TargetTree{
-					Id:      target.Id,
-					Label:   target.Label,
-					Handler: append([]Handler{}, target.Handler...),
+					Id:        target.Id,
+					Label:     target.Label,
+					Root:      target.Root,
+					Handler:   append([]Handler{}, target.Handler...),
+					OnFailure: target.OnFailure,
+					Timeout:   target.Timeout,
+					Upstream:  target.Upstream,
+					timeout:   target.timeout,
 				},
 			)
 			return nil
@@ -431,6 +637,7 @@ func FinalizeConfig(all []*Target, c *Config) error {
 		if visitErr != nil {
 			return errors.Wrapf(visitErr, "failed to collect downstream Target.Id values [%s]", t.Label)
 		}
+		t.Tree = orderTree(t.Tree)
 	}
 
 	c.startTarget = []Target{}
@@ -444,3 +651,8 @@ func FinalizeConfig(all []*Target, c *Config) error {
 
 	return nil
 }
+
+// validOnFailure returns true if the Target.OnFailure/Global.OnFailure value is supported.
+func validOnFailure(v string) bool {
+	return v == OnFailureStopTree || v == OnFailureSkipDependents || v == OnFailureContinue
+}
@@ -0,0 +1,257 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package boone
+
+import (
+	std_gob "encoding/gob"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	cage_zap "github.com/codeactual/boone/internal/cage/log/zap"
+	cage_file "github.com/codeactual/boone/internal/cage/os/file"
+)
+
+const (
+	// ControlDialTimeout is how long clients wait to connect to a control socket before falling back,
+	// e.g. on the session file.
+	ControlDialTimeout = time.Second
+
+	// ControlConnTimeout is how long the server and clients wait for one request/response exchange
+	// on a connection, e.g. so a client which never sends a request does not hold a connection open.
+	ControlConnTimeout = time.Second
+
+	// ControlOpSession requests the newest Session from the running instance.
+	ControlOpSession = "session"
+
+	// ControlOpRerun requests that the running instance run ControlRequest.TargetId again.
+	ControlOpRerun = "rerun"
+)
+
+// ControlRequest is sent by sub-commands, e.g. "status", to the control socket of a running instance.
+type ControlRequest struct {
+	// Op selects the operation, e.g. ControlOpSession.
+	Op string
+
+	// TargetId selects the target of ControlOpRerun.
+	TargetId string
+}
+
+// ControlResponse is sent by ControlServer in reply to a ControlRequest.
+type ControlResponse struct {
+	// Err is non-empty if the request could not be fulfilled.
+	Err string
+
+	// Session is the newest one received by ControlServer.SetSession.
+	Session Session
+}
+
+// ControlServer listens on a Unix socket, configured by Data.Control.File, and answers requests from
+// other boone processes about the running instance.
+type ControlServer struct {
+	// log receives debug/info-level messages.
+	log *zap.Logger
+
+	// file is the socket path.
+	file string
+
+	// listener accepts client connections.
+	listener net.Listener
+
+	// mu guards session.
+	mu sync.RWMutex
+
+	// session is the newest Session received from the UI.
+	session Session
+
+	// execReqCh receives ControlOpRerun requests, or is nil if EnableRerun was not called.
+	execReqCh chan ExecRequest
+
+	// targetTree holds a copy of Target.Tree indexed by Target.Id in order to rerun targets.
+	targetTree map[string][]TargetTree
+}
+
+// NewControlServer returns an instance which listens on the socket file.
+//
+// If the file already exists but no process accepts connections on it, the file is assumed to be left
+// over from an unclean shutdown and is replaced.
+func NewControlServer(log *zap.Logger, file string) (*ControlServer, error) {
+	if err := os.MkdirAll(filepath.Dir(file), dataDirPerm); err != nil {
+		return nil, errors.Wrapf(err, "failed to create control socket [%s] directory", file)
+	}
+
+	exists, _, err := cage_file.Exists(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to inspect control socket [%s]", file)
+	}
+	if exists {
+		if conn, dialErr := net.DialTimeout("unix", file, ControlDialTimeout); dialErr == nil {
+			_ = conn.Close()
+			return nil, errors.Errorf("control socket [%s] is already used by another instance", file)
+		}
+		if err = os.Remove(file); err != nil {
+			return nil, errors.Wrapf(err, "failed to remove stale control socket [%s]", file)
+		}
+	}
+
+	listener, err := net.Listen("unix", file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to listen on control socket [%s]", file)
+	}
+	if err = os.Chmod(file, dataFilePerm); err != nil {
+		_ = listener.Close()
+		return nil, errors.Wrapf(err, "failed to set control socket [%s] permissions", file)
+	}
+
+	return &ControlServer{log: log, file: file, listener: listener}, nil
+}
+
+// SetSession replaces the Session returned to ControlOpSession requests.
+//
+// The status list is copied because the UI continues to modify its own slice.
+func (s *ControlServer) SetSession(session Session) {
+	session.Statuses = append([]Status{}, session.Statuses...)
+	s.mu.Lock()
+	s.session = session
+	s.mu.Unlock()
+}
+
+// EnableRerun lets ControlOpRerun requests run the targets via the channel, e.g. Dispatcher.ExecReqCh.
+func (s *ControlServer) EnableRerun(targets []Target, execReqCh chan ExecRequest) {
+	targetTree := map[string][]TargetTree{}
+	for _, t := range targets {
+		targetTree[t.Id] = append([]TargetTree{}, t.Tree...)
+	}
+	s.mu.Lock()
+	s.execReqCh = execReqCh
+	s.targetTree = targetTree
+	s.mu.Unlock()
+}
+
+// Session returns the newest value received by SetSession.
+func (s *ControlServer) Session() Session {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.session
+}
+
+// Start accepts connections until Stop is called.
+//
+// It should run in its own goroutine because it blocks.
+func (s *ControlServer) Start() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return // Stop closed the listener
+		}
+		go s.serve(conn)
+	}
+}
+
+// Stop closes the listener, which also removes the socket file.
+func (s *ControlServer) Stop() {
+	if err := s.listener.Close(); err != nil {
+		s.log.Error("failed to close control socket", cage_zap.Tag("control"), zap.Error(err))
+	}
+}
+
+// serve answers one request per connection.
+func (s *ControlServer) serve(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	if err := conn.SetDeadline(time.Now().Add(ControlConnTimeout)); err != nil {
+		s.log.Error("failed to set control connection deadline", cage_zap.Tag("control"), zap.Error(err))
+		return
+	}
+
+	var req ControlRequest
+	if err := std_gob.NewDecoder(conn).Decode(&req); err != nil {
+		s.log.Error("failed to decode control request", cage_zap.Tag("control"), zap.Error(err))
+		return
+	}
+
+	s.log.Debug("control request", cage_zap.Tag("control"), zap.String("op", req.Op))
+
+	var res ControlResponse
+	switch req.Op {
+	case ControlOpSession:
+		res.Session = s.Session()
+	case ControlOpRerun:
+		if err := s.rerun(req.TargetId); err != nil {
+			res.Err = err.Error()
+		}
+	default:
+		res.Err = "unsupported control operation [" + req.Op + "]"
+	}
+
+	if err := std_gob.NewEncoder(conn).Encode(res); err != nil {
+		s.log.Error("failed to encode control response", cage_zap.Tag("control"), zap.String("op", req.Op), zap.Error(err))
+	}
+}
+
+// rerun enqueues the target with the activity details of its status, if listed, so that commands
+// receive the same template variables as the original run.
+func (s *ControlServer) rerun(targetId string) error {
+	s.mu.RLock()
+	execReqCh := s.execReqCh
+	tree, ok := s.targetTree[targetId]
+	s.mu.RUnlock()
+
+	if execReqCh == nil {
+		return errors.New("rerun is not enabled")
+	}
+	if !ok || len(tree) == 0 {
+		return errors.Errorf("target with Id [%s] not found", targetId)
+	}
+
+	status := Status{TargetId: targetId, TargetLabel: tree[0].Label}
+	for _, listed := range s.Session().Statuses {
+		if listed.TargetId == targetId {
+			status = listed
+			break
+		}
+	}
+
+	go func() {
+		execReqCh <- NewRerunRequest(status, tree, ExecCauseControl)
+	}()
+
+	return nil
+}
+
+// SendControlRequest connects to a running instance's control socket and returns its response.
+func SendControlRequest(file string, req ControlRequest) (res ControlResponse, err error) {
+	conn, err := net.DialTimeout("unix", file, ControlDialTimeout)
+	if err != nil {
+		return ControlResponse{}, errors.Wrapf(err, "failed to connect to control socket [%s]", file)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	if err = conn.SetDeadline(time.Now().Add(ControlConnTimeout)); err != nil {
+		return ControlResponse{}, errors.Wrapf(err, "failed to set control socket [%s] deadline", file)
+	}
+	if err = std_gob.NewEncoder(conn).Encode(req); err != nil {
+		return ControlResponse{}, errors.Wrapf(err, "failed to send control request [%s]", req.Op)
+	}
+	if err = std_gob.NewDecoder(conn).Decode(&res); err != nil {
+		return ControlResponse{}, errors.Wrapf(err, "failed to receive control response [%s]", req.Op)
+	}
+	if res.Err != "" {
+		return ControlResponse{}, errors.Errorf("control request [%s] failed: %s", req.Op, res.Err)
+	}
+
+	return res, nil
+}
@@ -7,10 +7,12 @@
 package boone
 
 import (
+	"bytes"
 	"context"
 	"fmt"
-	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -24,8 +26,6 @@ import (
 	cage_exec "github.com/codeactual/boone/internal/cage/os/exec"
 	"github.com/codeactual/boone/internal/cage/os/file/watcher"
 	cage_filepath "github.com/codeactual/boone/internal/cage/path/filepath"
-	cage_shell "github.com/codeactual/boone/internal/cage/shell"
-	cage_template "github.com/codeactual/boone/internal/cage/text/template"
 	cage_time "github.com/codeactual/boone/internal/cage/time"
 )
 
@@ -33,6 +33,37 @@ const (
 	// ExecRequestQueueTick defines how often to dequeue exectution requests which have been
 	// debounced and are ready to be fulfilled.
 	ExecRequestQueueTick = time.Second
+
+	// ExecCauseWatcher is the ExecRequest.Cause of requests sent by Watcher due to file activity.
+	ExecCauseWatcher = "watcher"
+
+	// ExecCauseRerun is the ExecRequest.Cause of requests sent by the UI to rerun a failed target.
+	ExecCauseRerun = "rerun"
+
+	// ExecCauseBrowser is the ExecRequest.Cause of requests sent by the UI when a target is
+	// triggered from the target browser.
+	ExecCauseBrowser = "browser"
+
+	// ExecCauseControl is the ExecRequest.Cause of requests received by ControlServer, e.g. from "boone lsp".
+	ExecCauseControl = "control"
+)
+
+// ExecAction selects how Dispatcher handles an ExecRequest.
+type ExecAction string
+
+const (
+	// ExecRun runs the target and its downstream targets. It is the default action.
+	ExecRun ExecAction = ""
+
+	// ExecCancel kills the target's in-progress commands, if any, through its TargetContext.Cancel.
+	ExecCancel ExecAction = "cancel"
+
+	// ExecMute makes Dispatcher ignore the target's file activity, i.e. requests with ExecCauseWatcher,
+	// until an ExecUnmute request is received. Requests which are already debouncing are not affected.
+	ExecMute ExecAction = "mute"
+
+	// ExecUnmute reverses ExecMute.
+	ExecUnmute ExecAction = "unmute"
 )
 
 // TargetContext enables Dispatcher to cancel a target's command execution if its watched
@@ -52,6 +83,9 @@ type TargetContext struct {
 // from a Watcher which detected target-specific activity, but it may also originate directly from
 // sub-commands, e.g. to support AutoStartTarget.
 type ExecRequest struct {
+	// Action selects whether to run, cancel, mute, or unmute the target. It defaults to ExecRun.
+	Action ExecAction
+
 	// Origin is a free-form value, currently only for logging, which indicates the cause
 	// of the request.
 	Cause string
@@ -81,15 +115,37 @@ type ExecRequest struct {
 	TargetLabel string
 }
 
+// NewRerunRequest returns a request to run the status's target again with the same trigger path and include.
+func NewRerunRequest(status Status, tree []TargetTree, cause string) ExecRequest {
+	op := watcher.Write
+	for _, o := range []watcher.Op{watcher.Create, watcher.Rename, watcher.Remove} {
+		if status.Op == o.String() {
+			op = o
+		}
+	}
+
+	return ExecRequest{
+		Cause:       cause,
+		Event:       watcher.Event{Path: status.Path, Op: op},
+		Include:     status.Include,
+		TargetId:    status.TargetId,
+		TargetLabel: status.TargetLabel,
+		Tree:        append([]TargetTree{}, tree...),
+	}
+}
+
 // Dispatcher receives ExecRequest messages from Watcher, runs/cancels target commands, and informs
 // the UI of new target statuses via channels.
 type Dispatcher struct {
-	// Clock supports timer mocking for debounce-sensitive tests.
+	// Clock supports timer mocking for debounce- and retry-sensitive tests.
 	Clock cage_time.Clock
 
 	// Cooldown is how long to wait after one command finishes before starting another.
 	Cooldown time.Duration
 
+	// TreeTimeout limits how long each runTarget call may take, if non-zero.
+	TreeTimeout time.Duration
+
 	// Executor supports os/exec.Cmd mocking for tests.
 	Executor cage_exec.Executor
 
@@ -134,6 +190,21 @@ type Dispatcher struct {
 	// For data races between the goroutine in cage/time.Debounce and the one which runs Dispatcher methods.
 	targetCtx sync.Map
 
+	// watchers holds the Watcher of every target with Include patterns, indexed by Target.Id.
+	watchers map[string]*Watcher
+
+	// muted holds the Id of every target whose file activity is ignored due to an ExecMute request.
+	//
+	// Values are unused.
+	muted sync.Map
+
+	// failedTests holds the CmdTemplateData.FailedTests value of every target which has run an
+	// Exec.Format ExecFormatGoTestJSON command, indexed by Target.Id.
+	failedTests sync.Map
+
+	// goGraphs holds the import graph of every target with Go enabled, indexed by Target.Id.
+	goGraphs map[string]*GoPackageGraph
+
 	// panicCh transports messages from Watcher to the CLI to support cleaner shutdowns.
 	panicCh chan<- interface{}
 }
@@ -169,6 +240,7 @@ func (d *Dispatcher) Start() {
 			zap.String("path", r.Event.Path),
 			zap.String("op", r.Event.Op.String()),
 			zap.String("cause", r.Cause),
+			zap.String("action", string(r.Action)),
 			zap.Duration("debounce", r.Debounce),
 		}
 	}
@@ -184,6 +256,42 @@ func (d *Dispatcher) Start() {
 			case req := <-d.ExecReqCh:
 				d.Log.Info("execution request", reqLogAttrs(req)...)
 
+				// Keep import graphs current even if the activity does not run their targets, e.g. muted.
+				if req.Cause == ExecCauseWatcher {
+					for _, g := range d.goGraphs {
+						g.Invalidate(req.Event.Path)
+					}
+				}
+
+				switch req.Action {
+				case ExecCancel:
+					v, found := d.targetCtx.Load(req.TargetId)
+					if !found {
+						d.Log.Debug("no context found for requested cancellation", reqLogAttrs(req)...)
+						continue
+					}
+					c, ok := v.(TargetContext)
+					if !ok {
+						panic(errors.Errorf("failed to access context for target [%s]", req.TargetLabel))
+					}
+					d.Log.Info("canceled target due to request", reqLogAttrs(req)...)
+					c.Cancel()
+					continue
+				case ExecMute:
+					d.muted.Store(req.TargetId, struct{}{})
+					continue
+				case ExecUnmute:
+					d.muted.Delete(req.TargetId)
+					continue
+				}
+
+				if req.Cause == ExecCauseWatcher {
+					if _, muted := d.muted.Load(req.TargetId); muted {
+						d.Log.Debug("ignored muted target", reqLogAttrs(req)...)
+						continue
+					}
+				}
+
 				req.RecvTime = time.Now()
 
 				// If any target handler is currently in running, consider it stale and immediately cancel it.
@@ -262,6 +370,19 @@ func (d *Dispatcher) Start() {
 
 					d.Log.Debug("debounce reset", logAttrs...)
 
+					debounceStatus := Status{
+						TargetId:    req.TargetId,
+						TargetLabel: req.TargetLabel,
+						Path:        req.Event.Path,
+						Op:          req.Event.Op.String(),
+						Include:     req.Include,
+						Cause:       TargetDebouncing,
+					}
+					select { // Only send if there's a receiver.
+					case d.TargetStartCh <- debounceStatus:
+					default:
+					}
+
 					d.debouncedRunner[req.TargetId][req.Event.Path](req)
 				} else {
 					enqueueStatus(req)
@@ -362,7 +483,133 @@ func (d *Dispatcher) runTarget(v interface{}) {
 	treeCtx, treeCancel := context.WithCancel(context.Background())
 	defer treeCancel()
 
-	for _, t := range req.Tree {
+	// Derive the GlobalConfig.TreeTimeout deadline from treeCtx so that activity can still cancel the tree.
+	if d.TreeTimeout > 0 {
+		var timeoutCancel context.CancelFunc
+		treeCtx, timeoutCancel = context.WithTimeout(treeCtx, d.TreeTimeout)
+		defer timeoutCancel()
+	}
+
+	runId := strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	// blockedBy maps the Id of each failed OnFailureSkipDependents target, and of each target skipped
+	// as a result, to the label of the failed target.
+	blockedBy := map[string]string{}
+	var treeFailed bool
+
+	// passed holds the labels of targets which passed, for describing how far the tree got if it times out.
+	var passed []string
+
+	// reported holds the Id of each target whose pass/fail/skip status was sent.
+	reported := map[string]bool{}
+
+	// stoppedBy is the label of the target whose failure ended the tree run, if any.
+	var stoppedBy string
+
+	// List the downstream targets as pending until they report a result, so that the tree appears
+	// in progress between targets, e.g. during Cooldown or GoPackageGraph.Resolve.
+	for _, t := range req.Tree[1:] {
+		select { // Only send if there's a receiver.
+		case d.TargetStartCh <- Status{TargetId: t.Id, TargetLabel: t.Label, Cause: TargetPending, UpstreamTargetLabel: req.TargetLabel}:
+		default:
+		}
+	}
+
+	// Replace the pending status of each downstream target which will not run because the tree run stopped early.
+	defer func() {
+		reason := "skipped because the tree run stopped"
+		if stoppedBy != "" {
+			reason = fmt.Sprintf("skipped because the tree run stopped at target [%s]", stoppedBy)
+		}
+		for _, t := range req.Tree[1:] {
+			if reported[t.Id] {
+				continue
+			}
+			select {
+			case d.TargetFailCh <- Status{
+				Err:                 reason,
+				Cause:               TargetSkipped,
+				StartTime:           time.Now(),
+				EndTime:             time.Now(),
+				Include:             req.Include,
+				TargetId:            t.Id,
+				TargetLabel:         t.Label,
+				UpstreamTargetLabel: req.TargetLabel,
+				Op:                  req.Event.Op.String(),
+				Path:                req.Event.Path,
+				Downstream:          downstreamLabels(req.Tree),
+			}:
+			default:
+			}
+		}
+	}()
+
+	// fail sends the status of the failed/canceled target and returns true if the target's OnFailure
+	// policy, or a cancellation of any of its handlers, ends the tree run.
+	fail := func(t TargetTree, status Status) bool {
+		reported[t.Id] = true
+		select {
+		case d.TargetFailCh <- status:
+		default:
+		}
+
+		if t.OnFailure == OnFailureStopTree || treeCtx.Err() != nil {
+			stoppedBy = t.Label
+			return true
+		}
+		for _, s := range append([]Status{status}, status.Failures...) {
+			if s.Cause == TargetCanceled {
+				stoppedBy = t.Label
+				return true
+			}
+		}
+
+		treeFailed = true
+		if t.OnFailure == OnFailureSkipDependents {
+			blockedBy[t.Id] = t.Label
+		}
+		return false
+	}
+
+	for treeIdx, t := range req.Tree {
+		var failedLabel string
+		for _, id := range t.Upstream {
+			if label, ok := blockedBy[id]; ok {
+				failedLabel = label
+				break
+			}
+		}
+		if failedLabel != "" {
+			blockedBy[t.Id] = failedLabel
+			reported[t.Id] = true
+
+			d.Log.Info(
+				"skipping target",
+				cage_zap.Tag("dispatch"),
+				zap.String("target", t.Label),
+				zap.String("failedTarget", failedLabel),
+			)
+
+			select {
+			case d.TargetFailCh <- Status{
+				Err:                 fmt.Sprintf("skipped because upstream target [%s] failed", failedLabel),
+				Cause:               TargetSkipped,
+				StartTime:           time.Now(),
+				EndTime:             time.Now(),
+				Include:             req.Include,
+				TargetId:            t.Id,
+				TargetLabel:         t.Label,
+				UpstreamTargetLabel: req.TargetLabel,
+				Op:                  req.Event.Op.String(),
+				Path:                req.Event.Path,
+				Downstream:          downstreamLabels(req.Tree),
+			}:
+			default:
+			}
+
+			continue
+		}
+
 		targetStartTime := time.Now()
 
 		// Allow activity on any target to cancel the tree as a whole. See comments above
@@ -373,164 +620,562 @@ func (d *Dispatcher) runTarget(v interface{}) {
 		d.targetCtx.Store(t.Id, TargetContext{Ctx: treeCtx, Cancel: treeCancel})
 		defer d.targetCtx.Delete(t.Id)
 
-		for _, handler := range t.Handler {
-			for _, e := range handler.Exec {
+		// Derive the Target.Timeout deadline from treeCtx so that the tree's cancellation and deadline also apply.
+		handlerCtx, handlerCancel := treeCtx, context.CancelFunc(func() {})
+		if t.timeout > 0 {
+			handlerCtx, handlerCancel = context.WithTimeout(treeCtx, t.timeout)
+		}
+
+		var packages, affectedPackages string
+		if g, ok := d.goGraphs[t.Id]; ok {
+			pkgs, affected, err := g.Resolve(handlerCtx, req.Event.Path)
+			if err != nil {
+				d.Log.Warn(
+					"failed to resolve packages, using all",
+					cage_zap.Tag("dispatch"),
+					zap.String("target", t.Label),
+					zap.String("path", req.Event.Path),
+					zap.Error(err),
+				)
+				pkgs, affected = []string{GoAllPackages}, []string{GoAllPackages}
+			}
+			packages, affectedPackages = strings.Join(pkgs, " "), strings.Join(affected, " ")
+		}
+
+		// Run each handler once all of its dependencies have finished, e.g. immediately after the previous
+		// handler by default. Results are indexed like t.Handler so they can be reported in declared order.
+		results := make([]handlerResult, len(t.Handler))
+		done := make([]chan struct{}, len(t.Handler))
+		panics := make([]interface{}, len(t.Handler))
+		for h := range t.Handler {
+			done[h] = make(chan struct{})
+		}
+
+		var wg sync.WaitGroup
+		for h := range t.Handler {
+			wg.Add(1)
+			go func(h int) {
+				defer wg.Done()
+				defer close(done[h])
+				defer func() { // re-panic in runTarget's goroutine so its recovery applies
+					if r := recover(); r != nil {
+						panics[h] = r
+						results[h].blocked = true
+					}
+				}()
+
+				handler := t.Handler[h]
+
+				for _, dep := range handler.deps {
+					<-done[dep]
+				}
+				for _, dep := range handler.deps {
+					if !results[dep].ok() {
+						results[h].blocked = true
+						return
+					}
+				}
+				// Report the handler as canceled/timed out, e.g. if a deadline passed during the Cooldown
+				// after the previous handler, so the target does not appear to pass.
+				if ctxErr := handlerCtx.Err(); ctxErr != nil {
+					cause := TargetCanceled
+					if ctxErr == context.DeadlineExceeded {
+						cause = TargetTimedOut
+					}
+					results[h] = handlerResult{
+						blocked: true,
+						status: &Status{
+							Err:                 ctxErr.Error(),
+							Cause:               cause,
+							StartTime:           time.Now(),
+							EndTime:             time.Now(),
+							Include:             req.Include,
+							TargetId:            t.Id,
+							TargetLabel:         t.Label,
+							HandlerLabel:        handler.Label,
+							UpstreamTargetLabel: req.TargetLabel,
+							Op:                  req.Event.Op.String(),
+							Path:                req.Event.Path,
+							Downstream:          downstreamLabels(req.Tree),
+						},
+					}
+					return
+				}
+
 				tmplData := CmdTemplateData{
-					Dir:          filepath.Dir(req.Event.Path),
-					HandlerLabel: handler.Label,
-					IncludeGlob:  req.Include.Pattern,
-					IncludeRoot:  req.Include.Root,
-					Path:         req.Event.Path,
-					TargetLabel:  t.Label,
+					AffectedPackages: affectedPackages,
+					Packages:         packages,
+					Dir:              filepath.Dir(req.Event.Path),
+					HandlerLabel:     handler.Label,
+					IncludeGlob:      req.Include.Pattern,
+					IncludeRoot:      req.Include.Root,
+					Path:             req.Event.Path,
+					Root:             t.Root,
+					RunId:            runId,
+					TargetId:         t.Id,
+					TargetLabel:      t.Label,
+				}
+				if req.Event.Path != "" {
+					tmplData.Op = req.Event.Op.String()
+					if relPath, relErr := filepath.Rel(t.Root, req.Event.Path); relErr == nil {
+						tmplData.RelPath = relPath
+					}
 				}
 
-				cmdBuf, err := cage_template.ExecuteBuffered(e.Cmd, tmplData)
-				if err != nil {
-					panic(errors.Wrapf(err, "failed to expand target [%s] command [%s]", t.Label, e.Cmd))
+				if reason := d.handlerSkipReason(handlerCtx, handler, req, tmplData); reason != "" {
+					d.Log.Info(
+						"skipping handler",
+						cage_zap.Tag("dispatch"),
+						zap.String("target", t.Label),
+						zap.String("handler", handler.Label),
+						zap.String("reason", reason),
+					)
+					results[h].skipped = reason
+					return
 				}
-				cmdExpanded := cmdBuf.String()
 
-				cmdParsed, err := cage_shell.Parse(cmdExpanded)
-				if err != nil {
-					panic(errors.Wrapf(err, "failed to parse target[%s] command [%s]", t.Label, cmdExpanded))
+				results[h] = d.runHandler(handlerCtx, req, t, handler, tmplData)
+			}(h)
+		}
+		wg.Wait()
+
+		// Select the limit which stopped the handlers, if any, before releasing handlerCtx.
+		var limit string
+		if treeCtx.Err() == context.DeadlineExceeded {
+			limit = fmt.Sprintf("Global.TreeTimeout [%s]", d.TreeTimeout)
+		} else if handlerCtx.Err() == context.DeadlineExceeded {
+			limit = fmt.Sprintf("Target.Timeout [%s]", t.Timeout)
+		}
+		handlerCancel()
+
+		for _, r := range panics {
+			if r != nil {
+				panic(r)
+			}
+		}
+
+		var flaky, skippedHandler []string
+		var warned, failures []Status
+		for h, r := range results {
+			flaky = append(flaky, r.flaky...)
+			if r.skipped != "" {
+				skippedHandler = append(skippedHandler, t.Handler[h].Label+": "+r.skipped)
+			}
+			if r.status != nil {
+				switch r.status.Cause {
+				case TargetWarned:
+					warned = append(warned, *r.status)
+				case TargetTimedOut:
+					status := *r.status
+					if r.execTimeout != "" {
+						status.Err = r.execTimeout
+					} else {
+						status.Err = limit
+					}
+					status.Err += " reached " + treeProgress(req.Tree, treeIdx, passed)
+					failures = append(failures, status)
+				default:
+					failures = append(failures, *r.status)
 				}
+			}
+		}
+		for n := range warned {
+			warned[n].SkippedHandler = skippedHandler
+		}
 
-				// Add a timeout just for this command (not the target/tree as a whole).
-				//
-				// cmdCancel is not used because treeCancel provides the same functionality for
-				// cancelling at the target level based on file activity.
-				cmdCtx, cmdCancel := context.WithTimeout(treeCtx, e.timeout)
-				defer cmdCancel()
+		// Only expose one problem per Target to the user, with the others attached to it.
+		if len(failures) > 0 {
+			status := failures[0]
+			status.SkippedHandler = skippedHandler
+			if len(failures) > 1 {
+				status.Failures = failures[1:]
+			}
+			if fail(t, status) {
+				return
+			}
+			continue
+		}
 
-				cmds := cage_exec.ArgToCmd(cmdCtx, cmdParsed...)
+		passed = append(passed, t.Label)
+		reported[t.Id] = true
 
-				cmdStrs := []string{}
-				for _, cmd := range cmds {
-					cmd.Env = append(os.Environ(), e.Env...)
-					cmd.Dir = e.Dir
+		select {
+		case d.TargetPassCh <- TargetPass{TargetId: t.Id, RunLen: time.Since(targetStartTime), Flaky: flaky, Warned: warned, SkippedHandler: skippedHandler}:
+		default:
+		}
+	}
 
-					cmdStrs = append(cmdStrs, cage_exec.CmdToString(cmd))
-				}
+	if treeFailed {
+		return
+	}
 
-				d.Log.Info(
-					"starting handler command",
-					cage_zap.Tag("dispatch"),
-					zap.String("target", t.Label),
-					zap.String("dispatchTarget", req.TargetLabel),
-					zap.String("handler", handler.Label),
-					zap.String("cmdExpanded", cmdExpanded),
-					zap.Strings("cmdStrs", cmdStrs),
-				)
+	select {
+	case d.TreePassCh <- TreePass{DispatchTargetId: req.TargetId}:
+	default:
+	}
+}
 
-				cmdStartTime := time.Now()
-				select { // Only send if there's a receiver.
-				case d.TargetStartCh <- Status{TargetId: t.Id, TargetLabel: t.Label, HandlerLabel: handler.Label, Path: req.Event.Path, StartTime: cmdStartTime, Cause: TargetStarted}:
-				default:
-				}
-				stdout, stderr, res, err := d.Executor.Buffered(cmdCtx, cmds...)
+// treeProgress describes how far a run of the tree got before the target at index cur stopped it.
+func treeProgress(tree []TargetTree, cur int, passed []string) string {
+	var remaining []string
+	for _, t := range tree[cur+1:] {
+		remaining = append(remaining, t.Label)
+	}
+
+	list := func(labels []string) string {
+		if len(labels) == 0 {
+			return "none"
+		}
+		return strings.Join(labels, ", ")
+	}
+
+	return fmt.Sprintf(
+		"at target %d/%d [%s] (passed: %s; remaining: %s)",
+		cur+1, len(tree), tree[cur].Label, list(passed), list(remaining),
+	)
+}
+
+// runHandler runs the handler's commands in declared order until one does not pass.
+//
+// It is called by runTarget, possibly at the same time as other handlers of the target. The input
+// context carries the tree's cancellation and any Target.Timeout/GlobalConfig.TreeTimeout deadline.
+func (d *Dispatcher) runHandler(ctx context.Context, req ExecRequest, t TargetTree, handler Handler, tmplData CmdTemplateData) handlerResult {
+	var flaky []string
+
+	for _, e := range handler.Exec {
+		if failedTests, ok := d.failedTests.Load(t.Id); ok {
+			tmplData.FailedTests = failedTests.(string)
+		}
 
-				ctxErr := cmdCtx.Err()
-				if ctxErr != nil {
-					err = ctxErr
+		env, err := e.Environ()
+		if err != nil {
+			status := Status{
+				Cmd:                 e.Cmd,
+				Dir:                 e.Dir,
+				Err:                 errors.Wrap(err, "failed to read command environment").Error(),
+				Cause:               TargetFailed,
+				StartTime:           time.Now(),
+				EndTime:             time.Now(),
+				Include:             req.Include,
+				TargetId:            t.Id,
+				TargetLabel:         t.Label,
+				HandlerLabel:        handler.Label,
+				UpstreamTargetLabel: req.TargetLabel,
+				Op:                  req.Event.Op.String(),
+				Path:                req.Event.Path,
+				Downstream:          downstreamLabels(req.Tree),
+			}
+
+			return handlerResult{status: &status, flaky: flaky}
+		}
+
+		cmdExpanded, err := e.ExpandCmd(tmplData, env)
+		if err != nil {
+			panic(errors.Wrapf(err, "failed to expand target [%s] command", t.Label))
+		}
+
+		cmdParsed, err := e.CmdArgs(cmdExpanded, env)
+		if err != nil {
+			panic(errors.Wrapf(err, "failed to parse target[%s] command [%s]", t.Label, cmdExpanded))
+		}
+
+		attempts := e.Retry.Attempts()
+
+		var (
+			stdout, stderr *bytes.Buffer
+			ctxErr         error
+			cmdStartTime   time.Time
+			pids           []int
+			attempt        int
+		)
+
+		for attempt = 1; ; attempt++ {
+			// Add a timeout just for this command (not the target/tree as a whole). It is canceled
+			// at the end of each attempt, rather than deferred, to release its timer before the next one.
+			cmdCtx, cmdCancel := context.WithTimeout(ctx, e.timeout)
+
+			cmds := cage_exec.ArgToCmd(cmdCtx, cmdParsed...)
+
+			cmdStrs := []string{}
+			for _, cmd := range cmds {
+				cmd.Env = env
+				cmd.Dir = e.Dir
+
+				cmdStrs = append(cmdStrs, cage_exec.CmdToString(cmd))
+			}
+
+			d.Log.Info(
+				"starting handler command",
+				cage_zap.Tag("dispatch"),
+				zap.String("target", t.Label),
+				zap.String("dispatchTarget", req.TargetLabel),
+				zap.String("handler", handler.Label),
+				zap.String("cmdExpanded", cmdExpanded),
+				zap.Strings("cmdStrs", cmdStrs),
+				zap.Int("attempt", attempt),
+			)
+
+			cmdStartTime = time.Now()
+			select { // Only send if there's a receiver.
+			case d.TargetStartCh <- Status{TargetId: t.Id, TargetLabel: t.Label, HandlerLabel: handler.Label, Path: req.Event.Path, StartTime: cmdStartTime, Cause: TargetStarted, Attempt: attempt, Attempts: attempts}:
+			default:
+			}
+			var res cage_exec.PipelineResult
+			stdout, stderr, res, err = d.Executor.Buffered(cmdCtx, cmds...)
+
+			// Read the cause before cmdCancel replaces it with context.Canceled.
+			ctxErr = cmdCtx.Err()
+			cmdCancel()
+			if ctxErr != nil {
+				err = ctxErr
+			}
+
+			pids = []int{}
+			pgids := []int{}
+			codes := []int{}
+			errs := []error{}
+			code := 0
+			for _, cmd := range cmds {
+				pids = append(pids, res.Cmd[cmd].Pid)
+				pgids = append(pgids, res.Cmd[cmd].Pgid)
+				codes = append(codes, res.Cmd[cmd].Code)
+				errs = append(errs, res.Cmd[cmd].Err)
+				if code == 0 {
+					code = res.Cmd[cmd].Code
 				}
+			}
 
-				pids := []int{}
-				pgids := []int{}
-				codes := []int{}
-				errs := []error{}
-				for _, cmd := range cmds {
-					pids = append(pids, res.Cmd[cmd].Pid)
-					pgids = append(pgids, res.Cmd[cmd].Pgid)
-					codes = append(codes, res.Cmd[cmd].Code)
-					errs = append(errs, res.Cmd[cmd].Err)
+			d.Log.Info(
+				"handler command finished",
+				cage_zap.Tag("dispatch"),
+				zap.String("target", t.Label),
+				zap.String("dispatchTarget", req.TargetLabel),
+				zap.String("handler", handler.Label),
+				zap.String("cmdExpanded", cmdExpanded),
+				zap.Strings("cmdStrs", cmdStrs),
+				zap.String("stdout", stdout.String()),
+				zap.String("stderr", stderr.String()),
+				zap.String("runLen", time.Since(cmdStartTime).String()),
+				zap.Ints("pids", pids),
+				zap.Ints("pgids", pgids),
+				zap.Ints("codes", codes),
+				zap.Errors("processErrs", errs),
+				zap.Error(err),
+				zap.Int("attempt", attempt),
+			)
+
+			// Output from a canceled/timed out command may be incomplete, so only evaluate finished ones.
+			if ctxErr == nil {
+				err = e.Evaluate(err, code, stdout.String(), stderr.String())
+			}
+
+			// Stop retrying if file activity canceled the tree, which will run again anyway.
+			if err == nil || attempt == attempts || ctx.Err() != nil || !e.Retry.Match(code, stdout.String(), stderr.String()) {
+				break
+			}
+
+			delay := e.Retry.Delay(attempt)
+			d.Log.Info(
+				"retrying handler command",
+				cage_zap.Tag("dispatch"),
+				zap.String("target", t.Label),
+				zap.String("handler", handler.Label),
+				zap.String("cmdExpanded", cmdExpanded),
+				zap.Int("attempt", attempt),
+				zap.Int("attempts", attempts),
+				zap.String("delay", delay.String()),
+			)
+
+			timer := d.Clock.NewTimer(delay)
+			select {
+			case <-timer.C():
+			case <-ctx.Done():
+				timer.Stop()
+			}
+			if ctx.Err() != nil {
+				ctxErr, err = ctx.Err(), ctx.Err()
+				break
+			}
+		}
+
+		if err == nil && attempt > 1 {
+			flaky = append(flaky, fmt.Sprintf("%s passed on %s", handler.Label, AttemptText(attempt, attempts)))
+		}
+
+		stdoutText := stdout.String()
+		var tests []TestResult
+		if e.Format == ExecFormatGoTestJSON {
+			tests, stdoutText = ParseGoTestJSON(stdoutText)
+
+			// Retain the last complete run's failures, not a partial list from a canceled run.
+			if ctxErr == nil {
+				d.failedTests.Store(t.Id, FailedTestsPattern(tests))
+			}
+		}
+
+		if err != nil {
+			// runTarget replaces the Err of TargetTimedOut statuses with a description of the limit reached.
+			cause := TargetFailed
+			var execTimeout string
+			if ctxErr == context.DeadlineExceeded {
+				cause = TargetTimedOut
+				if ctx.Err() == nil {
+					execTimeout = fmt.Sprintf("Exec.Timeout [%s]", e.Timeout)
 				}
+			} else if ctxErr != nil {
+				cause = TargetCanceled
+			}
+
+			status := Status{
+				Cmd:                 cmdExpanded,
+				Dir:                 e.Dir,
+				Diagnostics:         append(ParseDiagnostics(e.ProblemMatcher, stderr.String()), ParseDiagnostics(e.ProblemMatcher, stdoutText)...),
+				Stdout:              stdoutText,
+				Stderr:              stderr.String(),
+				Err:                 err.Error(),
+				Cause:               cause,
+				StartTime:           cmdStartTime,
+				EndTime:             time.Now(),
+				Pid:                 pids,
+				Attempt:             attempt,
+				Attempts:            attempts,
+				RunLen:              time.Since(cmdStartTime),
+				Include:             req.Include,
+				TargetId:            t.Id,
+				TargetLabel:         t.Label,
+				Tests:               tests,
+				HandlerLabel:        handler.Label,
+				UpstreamTargetLabel: req.TargetLabel,
+				Op:                  req.Event.Op.String(),
+				Path:                req.Event.Path,
+				Downstream:          downstreamLabels(req.Tree),
+			}
+
+			// Skip the handler's remaining commands but continue with the next handler and downstream targets.
+			if handler.AllowFailure && cause == TargetFailed {
+				status.Cause = TargetWarned
 
 				d.Log.Info(
-					"handler command finished",
+					"handler command failed, continuing due to AllowFailure",
 					cage_zap.Tag("dispatch"),
 					zap.String("target", t.Label),
-					zap.String("dispatchTarget", req.TargetLabel),
 					zap.String("handler", handler.Label),
 					zap.String("cmdExpanded", cmdExpanded),
-					zap.Strings("cmdStrs", cmdStrs),
-					zap.String("stdout", stdout.String()),
-					zap.String("stderr", stderr.String()),
-					zap.String("runLen", time.Since(cmdStartTime).String()),
-					zap.Ints("pids", pids),
-					zap.Ints("pgids", pgids),
-					zap.Ints("codes", codes),
-					zap.Errors("processErrs", errs),
 					zap.Error(err),
 				)
 
-				if err != nil {
-					downLabels := []string{}
-					for n, d := range req.Tree {
-						if n > 0 {
-							downLabels = append(downLabels, d.Label)
-						}
-					}
+				time.Sleep(d.Cooldown)
+				return handlerResult{status: &status, flaky: flaky}
+			}
 
-					cause := TargetFailed
-					if ctxErr != nil {
-						cause = TargetCanceled
-					}
+			return handlerResult{status: &status, flaky: flaky, execTimeout: execTimeout}
+		}
 
-					status := Status{
-						Cmd:                 cmdExpanded,
-						Stdout:              stdout.String(),
-						Stderr:              stderr.String(),
-						Err:                 err.Error(),
-						Cause:               cause,
-						StartTime:           cmdStartTime,
-						EndTime:             time.Now(),
-						Pid:                 pids,
-						RunLen:              time.Since(cmdStartTime),
-						Include:             req.Include,
-						TargetId:            t.Id,
-						TargetLabel:         t.Label,
-						HandlerLabel:        handler.Label,
-						UpstreamTargetLabel: req.TargetLabel,
-						Op:                  req.Event.Op.String(),
-						Downstream:          downLabels,
-					}
+		time.Sleep(d.Cooldown)
+	}
 
-					select {
-					case d.TargetFailCh <- status:
-					default:
-					}
+	return handlerResult{flaky: flaky}
+}
 
-					return // Only expose one problem per Target to the user
-				}
+// handlerSkipReason returns a description of the first Handler.If condition which is not met, or an
+// empty string if the handler should run.
+//
+// If the tree is canceled while the When command runs, the handler is not skipped so that its first
+// command reports the cancellation.
+func (d *Dispatcher) handlerSkipReason(ctx context.Context, handler Handler, req ExecRequest, tmplData CmdTemplateData) string {
+	var op string
+	if req.Event.Path != "" {
+		op = req.Event.Op.String()
+	}
+	reason, err := handler.If.Match(req.Event.Path, op)
+	if err != nil {
+		panic(errors.Wrapf(err, "failed to match handler [%s] If conditions", handler.Label))
+	}
+	if reason != "" || handler.If.When == "" {
+		return reason
+	}
 
-				time.Sleep(d.Cooldown)
+	when := handler.If.when
+
+	env, err := when.Environ()
+	if err != nil {
+		return errors.Wrap(err, "failed to read If.When environment").Error()
+	}
+
+	cmdExpanded, err := when.ExpandCmd(tmplData, env)
+	if err != nil {
+		panic(errors.Wrapf(err, "failed to expand handler [%s] If.When", handler.Label))
+	}
+
+	cmdParsed, err := when.CmdArgs(cmdExpanded, env)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse If.When [%s]", cmdExpanded).Error()
+	}
+
+	cmdCtx, cmdCancel := context.WithTimeout(ctx, when.timeout)
+	defer cmdCancel()
+
+	cmds := cage_exec.ArgToCmd(cmdCtx, cmdParsed...)
+	for _, cmd := range cmds {
+		cmd.Env = env
+		cmd.Dir = when.Dir
+	}
+
+	_, _, res, err := d.Executor.Buffered(cmdCtx, cmds...)
+	if ctx.Err() != nil {
+		return ""
+	}
+	if err != nil {
+		for _, cmd := range cmds {
+			if code := res.Cmd[cmd].Code; code != 0 {
+				return fmt.Sprintf("If.When [%s] exited with code %d", cmdExpanded, code)
 			}
 		}
+		return fmt.Sprintf("If.When [%s] failed: %s", cmdExpanded, err)
+	}
+	return ""
+}
 
-		select {
-		case d.TargetPassCh <- TargetPass{TargetId: t.Id, RunLen: time.Since(targetStartTime)}:
-		default:
+// downstreamLabels returns the labels of all targets in the tree except the activity-triggered one.
+func downstreamLabels(tree []TargetTree) []string {
+	labels := []string{}
+	for n, t := range tree {
+		if n > 0 {
+			labels = append(labels, t.Label)
 		}
 	}
+	return labels
+}
 
-	select {
-	case d.TreePassCh <- TreePass{DispatchTargetId: req.TargetId}:
-	default:
+// WatchedPathCount returns how many file/dir paths are watched for the target's write-activity.
+func (d *Dispatcher) WatchedPathCount(targetId string) int {
+	w, ok := d.watchers[targetId]
+	if !ok {
+		return 0
 	}
+	return w.PathCount()
 }
 
 // NewDispatcher returns a new instance which is already watching for writes to targets' configured
 // paths and sending messages to its channels about target run starts, failures, etc.
 func NewDispatcher(log *zap.Logger, targets []Target, panicCh chan interface{}, globalConfig GlobalConfig) (*Dispatcher, error) {
 	execReqCh := make(chan ExecRequest, 1)
-	targetStartCh := make(chan Status, 1)
+	// Leave room for a status per target because runTarget sends pending/skipped ones for the whole tree at once.
+	targetStartCh := make(chan Status, len(targets)+1)
 	targetPassCh := make(chan TargetPass, 1)
-	targetFailCh := make(chan Status, 1)
+	targetFailCh := make(chan Status, len(targets)+1)
 	treePassCh := make(chan TreePass, 1)
+	watchers := make(map[string]*Watcher)
+	goGraphs := make(map[string]*GoPackageGraph)
+	executor := cage_exec.CommonExecutor{}
 
 	for _, target := range targets {
 		var err error
 
+		if target.Go {
+			goGraphs[target.Id] = NewGoPackageGraph(executor, target.Root)
+		}
+
 		globs, err := GetTargetGlob(target.Include, target.Exclude)
 		if err != nil {
 			return nil, errors.Wrapf(err, "[target: %s]: failed to get target globs", target.Label)
@@ -553,6 +1198,7 @@ func NewDispatcher(log *zap.Logger, targets []Target, panicCh chan interface{},
 				Log:       log,
 			}
 			watch.SetInclude(includes)
+			watchers[target.Id] = &watch
 
 			watcherErr := fsnotify.AddSubscriber(&watch)
 			if watcherErr != nil {
@@ -584,13 +1230,16 @@ func NewDispatcher(log *zap.Logger, targets []Target, panicCh chan interface{},
 	return &Dispatcher{
 		Clock:         cage_time.RealClock{},
 		Cooldown:      globalConfig.GetCooldown(),
-		Executor:      cage_exec.CommonExecutor{},
+		TreeTimeout:   globalConfig.GetTreeTimeout(),
+		Executor:      executor,
 		Log:           log,
 		ExecReqCh:     execReqCh,
 		TargetStartCh: targetStartCh,
 		TargetPassCh:  targetPassCh,
 		TargetFailCh:  targetFailCh,
 		TreePassCh:    treePassCh,
+		watchers:      watchers,
+		goGraphs:      goGraphs,
 		panicCh:       panicCh,
 	}, nil
 }
@@ -0,0 +1,98 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package boone_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/codeactual/boone/internal/boone"
+	testkit_file "github.com/codeactual/boone/internal/cage/testkit/os/file"
+)
+
+type DispatchSuite struct {
+	suite.Suite
+
+	root string
+}
+
+func (suite *DispatchSuite) SetupTest() {
+	t := suite.T()
+	testkit_file.ResetTestdata(t)
+	_, suite.root = testkit_file.CreateDir(t, "target")
+}
+
+// diamond returns the targets of the tree build -> (lint, test) -> deploy, where deploy only passes if
+// both of its upstream targets already ran.
+func (suite *DispatchSuite) diamond(lintScript, testScript string) []*boone.Target {
+	return []*boone.Target{
+		shTarget(suite.root, "build", "touch build"),
+		shTarget(suite.root, "lint", lintScript, "build"),
+		shTarget(suite.root, "test", testScript, "build"),
+		shTarget(suite.root, "deploy", "[ -f lint ] && [ -f test ] && touch deploy", "lint", "test"),
+	}
+}
+
+func (suite *DispatchSuite) TestDiamond() {
+	t := suite.T()
+
+	targets := suite.diamond("touch lint", "touch test")
+	run := runTree(t, &boone.Dispatcher{}, targets...)
+
+	var tree []string
+	for _, tt := range targets[0].Tree {
+		tree = append(tree, tt.Id)
+	}
+	require.Exactly(t, []string{"build", "lint", "test", "deploy"}, tree)
+
+	require.Empty(t, run.fails)
+	var passed []string
+	for _, p := range run.passes {
+		passed = append(passed, p.TargetId)
+	}
+	require.Exactly(t, []string{"build", "lint", "test", "deploy"}, passed)
+	requireFile(t, suite.root, "deploy", true)
+}
+
+func (suite *DispatchSuite) TestDiamondSkipDependents() {
+	t := suite.T()
+
+	// The upstream target which the tree reaches last fails.
+	targets := suite.diamond("touch lint", "exit 1")
+	targets[2].OnFailure = boone.OnFailureSkipDependents
+	run := runTree(t, &boone.Dispatcher{}, targets...)
+
+	require.Len(t, run.passes, 2)
+	require.Len(t, run.fails, 2)
+	require.Exactly(t, "test", run.fails[0].TargetId)
+	require.Exactly(t, boone.TargetFailed, run.fails[0].Cause)
+	require.Exactly(t, "deploy", run.fails[1].TargetId)
+	require.Exactly(t, boone.TargetSkipped, run.fails[1].Cause)
+	require.Exactly(t, "skipped because upstream target [test] failed", run.fails[1].Err)
+	requireFile(t, suite.root, "lint", true)
+}
+
+func (suite *DispatchSuite) TestDiamondContinue() {
+	t := suite.T()
+
+	// The other upstream target still runs, and the downstream target is not skipped.
+	targets := suite.diamond("exit 1", "touch test")
+	targets[1].OnFailure = boone.OnFailureContinue
+	targets[3].Handler[0].Exec[0].Cmd = "[ -f test ] && touch deploy"
+	run := runTree(t, &boone.Dispatcher{}, targets...)
+
+	require.Len(t, run.passes, 3)
+	require.Len(t, run.fails, 1)
+	require.Exactly(t, "lint", run.fails[0].TargetId)
+	requireFile(t, suite.root, "deploy", true)
+}
+
+func TestDispatchSuite(t *testing.T) {
+	suite.Run(t, new(DispatchSuite))
+}
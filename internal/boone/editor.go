@@ -0,0 +1,111 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package boone
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	cage_shell "github.com/codeactual/boone/internal/cage/shell"
+	cage_template "github.com/codeactual/boone/internal/cage/text/template"
+)
+
+const (
+	// DefaultEditor is the default Global.Editor value.
+	DefaultEditor = "$EDITOR +{{.Line}} {{.File}}"
+
+	// FallbackEditor replaces $EDITOR in DefaultEditor if the variable is empty.
+	FallbackEditor = "vi"
+)
+
+// locationPattern matches "file:line" and "file:line:col" locations in command output.
+//
+// The file must have an extension to avoid matching values such as timestamps. A leading ANSI
+// escape sequence is included in the match so it is not mistaken for part of the file.
+var locationPattern = regexp.MustCompile(`(?:\x1b\[[0-9;]*m)?(?P<file>[^\s:"'()<>\[\]\x1b]+\.\w+):(?P<line>\d+)(?::(?P<column>\d+))?`)
+
+// EditorTemplateData defines the variables available in Global.Editor.
+type EditorTemplateData struct {
+	// File is the absolute path of the location's file.
+	File string
+
+	// Line is 1-based.
+	Line int
+
+	// Column is 1-based.
+	Column int
+}
+
+// ParseLocation returns the first "file:line[:col]" location found in the string.
+//
+// ANSI escape sequences are ignored.
+func ParseLocation(s string) (loc Diagnostic, ok bool) {
+	s = ansiPattern.ReplaceAllString(s, "")
+
+	match := locationPattern.FindStringSubmatch(s)
+	if match == nil {
+		return Diagnostic{}, false
+	}
+
+	loc.File = match[1]
+	loc.Line, _ = strconv.Atoi(match[2])
+	loc.Column, _ = strconv.Atoi(match[3])
+
+	return loc, true
+}
+
+// EditorCmd returns the command which opens the location in an editor.
+//
+// The command string is expanded from the template, e.g. Global.Editor, and then parsed like Exec.Cmd.
+// If the template is empty, DefaultEditor is used. Relative location files are resolved from dir,
+// e.g. the Exec.Dir of the command whose output contained the location.
+func EditorCmd(tmpl string, loc Diagnostic, dir string) (*exec.Cmd, error) {
+	if tmpl == "" {
+		// Substitute the variable before parsing, instead of letting the parser expand it, so that values
+		// with arguments, e.g. "code -w", are split.
+		editor := os.Getenv("EDITOR")
+		if editor == "" {
+			editor = FallbackEditor
+		}
+		tmpl = strings.Replace(DefaultEditor, "$EDITOR", editor, 1)
+	}
+
+	data := EditorTemplateData{File: loc.File, Line: loc.Line, Column: loc.Column}
+	if !filepath.IsAbs(data.File) {
+		data.File = filepath.Join(dir, data.File)
+	}
+	if data.Line < 1 {
+		data.Line = 1
+	}
+	if data.Column < 1 {
+		data.Column = 1
+	}
+
+	cmdBuf, err := cage_template.ExecuteBuffered(tmpl, data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to expand editor command [%s]", tmpl)
+	}
+
+	args, err := cage_shell.Parse(cmdBuf.String())
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse editor command [%s]", cmdBuf.String())
+	}
+	if len(args) != 1 || len(args[0]) == 0 {
+		return nil, errors.Errorf("editor command [%s] must be a single command without pipes", cmdBuf.String())
+	}
+
+	cmd := exec.Command(args[0][0], args[0][1:]...) // #nosec G204
+	cmd.Dir = dir
+
+	return cmd, nil
+}
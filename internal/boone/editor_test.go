@@ -0,0 +1,105 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package boone_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/codeactual/boone/internal/boone"
+)
+
+type EditorSuite struct {
+	suite.Suite
+
+	origEditor string
+}
+
+func (suite *EditorSuite) SetupTest() {
+	suite.origEditor = os.Getenv("EDITOR")
+}
+
+func (suite *EditorSuite) TearDownTest() {
+	require.NoError(suite.T(), os.Setenv("EDITOR", suite.origEditor))
+}
+
+func (suite *EditorSuite) TestParseLocation() {
+	t := suite.T()
+
+	cases := []struct {
+		id       string
+		s        string
+		expected boone.Diagnostic
+		ok       bool
+	}{
+		{id: "line and column", s: "api/handler.go:12:5: undefined: x", expected: boone.Diagnostic{File: "api/handler.go", Line: 12, Column: 5}, ok: true},
+		{id: "line only", s: "    handler_test.go:31: expected 200", expected: boone.Diagnostic{File: "handler_test.go", Line: 31}, ok: true},
+		{id: "absolute", s: "at (/src/app.ts:3:14)", expected: boone.Diagnostic{File: "/src/app.ts", Line: 3, Column: 14}, ok: true},
+		{id: "ansi", s: "\x1b[31mapi/handler.go:12\x1b[0m", expected: boone.Diagnostic{File: "api/handler.go", Line: 12}, ok: true},
+		{id: "timestamp", s: "started at 12:30:45"},
+		{id: "none", s: "FAIL"},
+	}
+
+	for _, c := range cases {
+		actual, ok := boone.ParseLocation(c.s)
+		require.Exactly(t, c.ok, ok, c.id)
+		require.Exactly(t, c.expected, actual, c.id)
+	}
+}
+
+func (suite *EditorSuite) TestEditorCmd() {
+	t := suite.T()
+
+	require.NoError(t, os.Setenv("EDITOR", "code -w"))
+
+	cases := []struct {
+		id       string
+		tmpl     string
+		loc      boone.Diagnostic
+		expected []string
+	}{
+		{
+			id:       "default template",
+			loc:      boone.Diagnostic{File: "api/handler.go", Line: 12, Column: 5},
+			expected: []string{"code", "-w", "+12", "/proj/api/handler.go"},
+		},
+		{
+			id:       "custom template",
+			tmpl:     "subl {{.File}}:{{.Line}}:{{.Column}}",
+			loc:      boone.Diagnostic{File: "api/handler.go", Line: 12, Column: 5},
+			expected: []string{"subl", "/proj/api/handler.go:12:5"},
+		},
+		{
+			id:       "absolute file and missing line/column",
+			tmpl:     "subl {{.File}}:{{.Line}}:{{.Column}}",
+			loc:      boone.Diagnostic{File: "/other/main.go"},
+			expected: []string{"subl", "/other/main.go:1:1"},
+		},
+	}
+
+	for _, c := range cases {
+		cmd, err := boone.EditorCmd(c.tmpl, c.loc, "/proj")
+		require.NoError(t, err, c.id)
+		require.Exactly(t, c.expected, cmd.Args, c.id)
+		require.Exactly(t, "/proj", cmd.Dir, c.id)
+	}
+
+	require.NoError(t, os.Setenv("EDITOR", ""))
+	cmd, err := boone.EditorCmd("", boone.Diagnostic{File: "a.go", Line: 3}, "/proj")
+	require.NoError(t, err)
+	require.Exactly(t, []string{boone.FallbackEditor, "+3", "/proj/a.go"}, cmd.Args)
+
+	_, err = boone.EditorCmd("vim {{.File}} | cat", boone.Diagnostic{File: "a.go"}, "/proj")
+	require.Error(t, err)
+}
+
+func TestEditorSuite(t *testing.T) {
+	suite.Run(t, new(EditorSuite))
+}
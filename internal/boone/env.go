@@ -0,0 +1,101 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package boone
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// envSource holds one level's environment configuration, e.g. Global.Env and Global.EnvFile.
+type envSource struct {
+	// env holds "KEY=VALUE" pairs.
+	env []string
+
+	// envFile holds absolute paths to .env-style files.
+	envFile []string
+}
+
+// read returns the pairs from the files, in declared order, followed by env.
+//
+// The files are read on every call so that edits take effect at the next run.
+func (s envSource) read() ([]string, error) {
+	var lists [][]string
+	for _, name := range s.envFile {
+		pairs, err := ReadEnvFile(name)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		lists = append(lists, pairs)
+	}
+	return MergeEnv(append(lists, s.env)...), nil
+}
+
+// ReadEnvFile returns the "KEY=VALUE" pairs from a .env-style file.
+func ReadEnvFile(name string) ([]string, error) {
+	f, err := os.Open(name) // #nosec G304
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open env file [%s]", name)
+	}
+	defer f.Close()
+
+	pairs, err := ParseEnvFile(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse env file [%s]", name)
+	}
+	return pairs, nil
+}
+
+// ParseEnvFile returns the "KEY=VALUE" pairs from .env-style content.
+//
+// Each non-empty line which does not begin with "#" must hold a "KEY=VALUE" pair, optionally prefixed
+// with "export ". Values may be wrapped in single or double quotes, which are removed. Variables in values
+// are not expanded.
+func ParseEnvFile(r io.Reader) (pairs []string, err error) {
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		kv := strings.SplitN(strings.TrimPrefix(line, "export "), "=", 2)
+		key := strings.TrimSpace(kv[0])
+		if len(kv) != 2 || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, errors.Errorf("line %d is not a KEY=VALUE pair [%s]", n, line)
+		}
+
+		val := strings.TrimSpace(kv[1])
+		if len(val) >= 2 && (val[0] == '"' || val[0] == '\'') && val[len(val)-1] == val[0] {
+			val = val[1 : len(val)-1]
+		}
+
+		pairs = append(pairs, key+"="+val)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return pairs, nil
+}
+
+// resolveEnvFiles returns the paths with relative ones joined to the directory.
+func resolveEnvFiles(dir string, names []string) []string {
+	var resolved []string
+	for _, name := range names {
+		if filepath.IsAbs(name) {
+			resolved = append(resolved, name)
+		} else {
+			resolved = append(resolved, filepath.Join(dir, name))
+		}
+	}
+	return resolved
+}
@@ -0,0 +1,302 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package boone_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/codeactual/boone/internal/boone"
+	cage_exec "github.com/codeactual/boone/internal/cage/os/exec"
+	testkit_file "github.com/codeactual/boone/internal/cage/testkit/os/file"
+)
+
+type ExecSuite struct {
+	suite.Suite
+}
+
+func (suite *ExecSuite) SetupTest() {
+	testkit_file.ResetTestdata(suite.T())
+}
+
+func (suite *ExecSuite) writeFile(name, content string) {
+	t := suite.T()
+	p := filepath.Join(testkit_file.DynamicDataDirAbs(t), name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(p), 0700))
+	require.NoError(t, ioutil.WriteFile(p, []byte(content), 0600))
+}
+
+// run executes the command like Dispatcher and returns its standard output.
+func (suite *ExecSuite) run(exe boone.Exec, data boone.CmdTemplateData) (string, error) {
+	t := suite.T()
+
+	env, err := exe.Environ()
+	require.NoError(t, err)
+
+	cmdExpanded, err := exe.ExpandCmd(data, env)
+	require.NoError(t, err)
+
+	args, err := exe.CmdArgs(cmdExpanded, env)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), exe.GetTimeout())
+	defer cancel()
+
+	cmds := cage_exec.ArgToCmd(ctx, args...)
+	for _, cmd := range cmds {
+		cmd.Dir = exe.Dir
+		cmd.Env = env
+	}
+
+	stdout, _, _, err := cage_exec.CommonExecutor{}.Buffered(ctx, cmds...)
+	return stdout.String(), err
+}
+
+func (suite *ExecSuite) TestCmdArgs() {
+	t := suite.T()
+
+	exe, err := finalizeExec(boone.Exec{Cmd: "echo a b | wc -w"})
+	require.NoError(t, err)
+	args, err := exe.CmdArgs("echo a b | wc -w", nil)
+	require.NoError(t, err)
+	require.Exactly(t, [][]string{{"echo", "a", "b"}, {"wc", "-w"}}, args)
+
+	exe, err = finalizeExec(boone.Exec{Cmd: "echo a b | wc -w", Shell: "bash -o pipefail -c"})
+	require.NoError(t, err)
+	args, err = exe.CmdArgs("echo a b | wc -w", nil)
+	require.NoError(t, err)
+	require.Exactly(t, [][]string{{"bash", "-o", "pipefail", "-c", "echo a b | wc -w"}}, args)
+}
+
+func (suite *ExecSuite) TestMergeEnv() {
+	t := suite.T()
+
+	require.Exactly(
+		t,
+		[]string{"A=3", "B=2", "C=", "D=4=4"},
+		boone.MergeEnv([]string{"A=1", "B=2"}, nil, []string{"C", "A=3", "D=4=4"}),
+	)
+	require.Exactly(t, []string{}, boone.MergeEnv())
+
+	lookup := boone.EnvLookup([]string{"A=1", "B=2", "A=3"})
+	require.Exactly(t, "3", lookup("A"))
+	require.Exactly(t, "2", lookup("B"))
+	require.Exactly(t, "", lookup("C"))
+}
+
+func (suite *ExecSuite) TestEnviron() {
+	t := suite.T()
+
+	require.NoError(t, os.Setenv("BOONE_TEST_PROCESS", "process"))
+	require.NoError(t, os.Setenv("BOONE_TEST_EXEC", "process"))
+	require.NoError(t, os.Setenv("FORCE_COLOR", "process"))
+	defer func() {
+		require.NoError(t, os.Unsetenv("BOONE_TEST_PROCESS"))
+		require.NoError(t, os.Unsetenv("BOONE_TEST_EXEC"))
+		require.NoError(t, os.Unsetenv("FORCE_COLOR"))
+	}()
+
+	exe, err := finalizeExec(boone.Exec{
+		Cmd:   "echo $BOONE_TEST_PROCESS ${BOONE_TEST_EXEC} $FORCE_COLOR $CLICOLOR",
+		Color: boone.ExecColorForce,
+		Env:   []string{"BOONE_TEST_EXEC=exec", "CLICOLOR=exec"},
+	})
+	require.NoError(t, err)
+
+	// Precedence: process < Color < Env
+	env, err := exe.Environ()
+	require.NoError(t, err)
+	lookup := boone.EnvLookup(env)
+	require.Exactly(t, "process", lookup("BOONE_TEST_PROCESS"))
+	require.Exactly(t, "exec", lookup("BOONE_TEST_EXEC"))
+	require.Exactly(t, "1", lookup("FORCE_COLOR"))
+	require.Exactly(t, "exec", lookup("CLICOLOR"))
+
+	// Cmd expansion and the command itself see the same environment.
+	stdout, err := suite.run(exe, boone.CmdTemplateData{})
+	require.NoError(t, err)
+	require.Exactly(t, "process exec 1 exec\n", stdout)
+
+	// The shell expands variables from the same environment.
+	exe, err = finalizeExec(boone.Exec{
+		Cmd:   `echo "$BOONE_TEST_EXEC $FORCE_COLOR"`,
+		Color: boone.ExecColorForce,
+		Env:   []string{"BOONE_TEST_EXEC=exec"},
+		Shell: "sh -c",
+	})
+	require.NoError(t, err)
+	stdout, err = suite.run(exe, boone.CmdTemplateData{})
+	require.NoError(t, err)
+	require.Exactly(t, "exec 1\n", stdout)
+
+	// The env template function also reads the command environment instead of the process one.
+	exe, err = finalizeExec(boone.Exec{
+		Cmd:   `echo {{env "BOONE_TEST_PROCESS"}} {{env "BOONE_TEST_EXEC"}} {{env "FORCE_COLOR"}} {{env "BOONE_TEST_UNSET" "default"}}`,
+		Color: boone.ExecColorForce,
+		Env:   []string{"BOONE_TEST_EXEC=exec"},
+	})
+	require.NoError(t, err)
+	stdout, err = suite.run(exe, boone.CmdTemplateData{})
+	require.NoError(t, err)
+	require.Exactly(t, "process exec 1 default\n", stdout)
+}
+
+func (suite *ExecSuite) TestEnvironLayers() {
+	t := suite.T()
+
+	root := testkit_file.DynamicDataDirAbs(t)
+	suite.writeFile("global.env", "# global\nL1=global-file\nL2=global-file\nL3=global-file\nL4=global-file\nL5=global-file\nL6=global-file\n")
+	suite.writeFile("target.env", "export L3=target-file\nL4='target file'\nL5=\"target-file\"\nL6=target-file\n")
+	suite.writeFile("sub/exec.env", "\nL5=exec-file\nL6=exec-file\n")
+
+	target := &boone.Target{
+		Label:   "some target",
+		Root:    root,
+		Env:     []string{"L4=target", "L5=target", "L6=target"},
+		EnvFile: []string{"target.env"},
+		Handler: []boone.Handler{{
+			Label: "some handler",
+			Exec: []boone.Exec{{
+				Cmd:     "true",
+				Dir:     "sub",
+				Env:     []string{"L6=exec"},
+				EnvFile: []string{"exec.env"},
+			}},
+		}},
+	}
+	config := &boone.Config{
+		Global: boone.GlobalConfig{
+			Env:     []string{"L2=global", "L3=global", "L4=global", "L5=global", "L6=global"},
+			EnvFile: []string{filepath.Join(testkit_file.DynamicDataDir(), "global.env")},
+		},
+	}
+	require.NoError(t, boone.FinalizeConfig([]*boone.Target{target}, config))
+
+	// Precedence: process < Global.EnvFile < Global.Env < Target.EnvFile < Target.Env < Exec.EnvFile < Exec.Env
+	env, err := target.Handler[0].Exec[0].Environ()
+	require.NoError(t, err)
+	lookup := boone.EnvLookup(env)
+	require.Exactly(t, "global-file", lookup("L1"))
+	require.Exactly(t, "global", lookup("L2"))
+	require.Exactly(t, "target-file", lookup("L3"))
+	require.Exactly(t, "target", lookup("L4"))
+	require.Exactly(t, "exec-file", lookup("L5"))
+	require.Exactly(t, "exec", lookup("L6"))
+
+	// Files are read again for each run.
+	suite.writeFile("sub/exec.env", "L5=exec-file-edited\n")
+	env, err = target.Handler[0].Exec[0].Environ()
+	require.NoError(t, err)
+	require.Exactly(t, "exec-file-edited", boone.EnvLookup(env)("L5"))
+
+	require.NoError(t, os.Remove(filepath.Join(root, "sub", "exec.env")))
+	_, err = target.Handler[0].Exec[0].Environ()
+	require.Error(t, err)
+}
+
+func (suite *ExecSuite) TestEnvironColorOverride() {
+	t := suite.T()
+
+	target := &boone.Target{
+		Label: "some target",
+		Root:  testkit_file.DynamicDataDir(),
+		Env:   []string{"NO_COLOR=1", "FORCE_COLOR=0"},
+		Handler: []boone.Handler{{
+			Label: "some handler",
+			Exec:  []boone.Exec{{Cmd: "true", Color: boone.ExecColorForce}},
+		}},
+	}
+	config := &boone.Config{
+		Global: boone.GlobalConfig{Env: []string{"CLICOLOR=0"}},
+	}
+	require.NoError(t, boone.FinalizeConfig([]*boone.Target{target}, config))
+
+	// Precedence: Color < Global.Env < Target.Env
+	env, err := target.Handler[0].Exec[0].Environ()
+	require.NoError(t, err)
+	lookup := boone.EnvLookup(env)
+	require.Exactly(t, "1", lookup("NO_COLOR"))
+	require.Exactly(t, "0", lookup("FORCE_COLOR"))
+	require.Exactly(t, "0", lookup("CLICOLOR"))
+	require.Exactly(t, "1", lookup("CLICOLOR_FORCE"))
+}
+
+func (suite *ExecSuite) TestParseEnvFile() {
+	t := suite.T()
+
+	pairs, err := boone.ParseEnvFile(strings.NewReader(strings.Join([]string{
+		"# comment",
+		"",
+		"A=1",
+		"  export B = two words ",
+		`C="double $A"`,
+		"D='single'",
+		`E="unbalanced`,
+		"F=",
+		"G=a=b",
+	}, "\n")))
+	require.NoError(t, err)
+	require.Exactly(t, []string{"A=1", "B=two words", "C=double $A", "D=single", `E="unbalanced`, "F=", "G=a=b"}, pairs)
+
+	_, err = boone.ParseEnvFile(strings.NewReader("A=1\nB\n"))
+	require.EqualError(t, err, "line 2 is not a KEY=VALUE pair [B]")
+
+	_, err = boone.ParseEnvFile(strings.NewReader("A B=1\n"))
+	require.Error(t, err)
+}
+
+func (suite *ExecSuite) TestShell() {
+	t := suite.T()
+
+	exe, err := finalizeExec(boone.Exec{
+		Cmd:   `false || echo "$(echo {{.TargetLabel}})" > out.txt && cat *.txt; echo done`,
+		Shell: "sh -c",
+	})
+	require.NoError(t, err)
+
+	stdout, err := suite.run(exe, boone.CmdTemplateData{TargetLabel: "some label"})
+	require.NoError(t, err)
+	require.Exactly(t, "some label\ndone\n", stdout)
+
+	exe.Cmd = "exit 3"
+	_, err = suite.run(exe, boone.CmdTemplateData{})
+	require.Error(t, err)
+}
+
+func (suite *ExecSuite) TestShellKillsProcessGroup() {
+	t := suite.T()
+
+	exe, err := finalizeExec(boone.Exec{Cmd: "sleep 30; sleep 30", Shell: "sh -c", Timeout: "100ms"})
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = suite.run(exe, boone.CmdTemplateData{})
+	require.Error(t, err)
+	require.True(t, time.Since(start) < 10*time.Second, time.Since(start).String())
+}
+
+func (suite *ExecSuite) TestShellInvalid() {
+	t := suite.T()
+
+	_, err := finalizeExec(boone.Exec{Cmd: "true", Shell: "bash -c | cat"})
+	require.Error(t, err)
+
+	_, err = finalizeExec(boone.Exec{Cmd: "true", Shell: `bash "-c`})
+	require.Error(t, err)
+}
+
+func TestExecSuite(t *testing.T) {
+	suite.Run(t, new(ExecSuite))
+}
@@ -0,0 +1,130 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package boone_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/codeactual/boone/internal/boone"
+	cage_exec "github.com/codeactual/boone/internal/cage/os/exec"
+	cage_file "github.com/codeactual/boone/internal/cage/os/file"
+	"github.com/codeactual/boone/internal/cage/testkit"
+	testkit_file "github.com/codeactual/boone/internal/cage/testkit/os/file"
+	cage_time "github.com/codeactual/boone/internal/cage/time"
+)
+
+// finalizeHandler returns the Handler after FinalizeConfig processes it as the only handler of a target.
+func finalizeHandler(root string, handler boone.Handler) (boone.Handler, error) {
+	target := &boone.Target{
+		Label:   "some target",
+		Root:    root,
+		Handler: []boone.Handler{handler},
+	}
+	if err := boone.FinalizeConfig([]*boone.Target{target}, &boone.Config{}); err != nil {
+		return boone.Handler{}, err
+	}
+	return target.Handler[0], nil
+}
+
+// finalizeExec returns the Exec after FinalizeConfig processes it as the only command of a target
+// rooted in the dynamic testdata dir.
+func finalizeExec(exe boone.Exec) (boone.Exec, error) {
+	handler, err := finalizeHandler(testkit_file.DynamicDataDir(), boone.Handler{Label: "some handler", Exec: []boone.Exec{exe}})
+	if err != nil {
+		return boone.Exec{}, err
+	}
+	return handler.Exec[0], nil
+}
+
+// shHandler returns a handler which runs the script with "sh -c".
+func shHandler(label, script string) boone.Handler {
+	return boone.Handler{Label: label, Exec: []boone.Exec{{Cmd: script, Shell: "sh -c"}}}
+}
+
+// shTarget returns a target with one handler, of the same label, which runs the script with "sh -c".
+func shTarget(root, label, script string, upstream ...string) *boone.Target {
+	return &boone.Target{
+		Label:    label,
+		Id:       label,
+		Root:     root,
+		Upstream: upstream,
+		Handler:  []boone.Handler{shHandler(label, script)},
+	}
+}
+
+// requireFile asserts whether the file, relative to root, exists.
+func requireFile(t *testing.T, root, name string, expected bool) {
+	exists, _, err := cage_file.Exists(filepath.Join(root, name))
+	require.NoError(t, err)
+	require.Exactly(t, expected, exists, name)
+}
+
+// treeRun holds the messages which a Dispatcher sent during a run of a target tree.
+type treeRun struct {
+	// started holds the statuses sent via Dispatcher.TargetStartCh, e.g. pending and started.
+	started []boone.Status
+
+	// passes holds the messages sent via Dispatcher.TargetPassCh, in the order received.
+	passes []boone.TargetPass
+
+	// fails holds the statuses sent via Dispatcher.TargetFailCh, in the order received.
+	fails []boone.Status
+}
+
+// runTree finalizes the targets and dispatches the tree of the first one. It returns after every target
+// in the tree passed, failed, or was skipped.
+//
+// The dispatcher only needs fields which the test is about, e.g. TreeTimeout. Executor, Log, Clock,
+// and the channels are populated if empty.
+func runTree(t *testing.T, dispatcher *boone.Dispatcher, targets ...*boone.Target) treeRun {
+	require.NoError(t, boone.FinalizeConfig(targets, &boone.Config{}))
+
+	if dispatcher.Executor == nil {
+		dispatcher.Executor = cage_exec.CommonExecutor{}
+	}
+	if dispatcher.Log == nil {
+		dispatcher.Log = testkit.NewZapLogger()
+	}
+	if dispatcher.Clock == nil {
+		dispatcher.Clock = cage_time.RealClock{}
+	}
+	dispatcher.ExecReqCh = make(chan boone.ExecRequest, 1)
+	dispatcher.TargetStartCh = make(chan boone.Status, 10*len(targets))
+	dispatcher.TargetPassCh = make(chan boone.TargetPass, len(targets))
+	dispatcher.TargetFailCh = make(chan boone.Status, len(targets))
+	dispatcher.TreePassCh = make(chan boone.TreePass, 1)
+
+	go dispatcher.Start()
+	defer dispatcher.Stop()
+
+	first := targets[0]
+	dispatcher.ExecReqCh <- boone.ExecRequest{TargetId: first.Id, TargetLabel: first.Label, Tree: first.Tree}
+
+	// The time limit only keeps a broken run from blocking the test, and exceeds how long killed
+	// commands take to be reaped.
+	var run treeRun
+	for finished := 0; finished < len(first.Tree); finished++ {
+		select {
+		case p := <-dispatcher.TargetPassCh:
+			run.passes = append(run.passes, p)
+		case f := <-dispatcher.TargetFailCh:
+			run.fails = append(run.fails, f)
+		case <-time.After(10 * time.Second):
+			require.FailNow(t, "tree run did not finish")
+		}
+	}
+
+	for len(dispatcher.TargetStartCh) > 0 {
+		run.started = append(run.started, <-dispatcher.TargetStartCh)
+	}
+
+	return run
+}
@@ -0,0 +1,291 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package boone
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	cage_exec "github.com/codeactual/boone/internal/cage/os/exec"
+	cage_file "github.com/codeactual/boone/internal/cage/os/file"
+)
+
+// GoAllPackages is the CmdTemplateData.Packages/AffectedPackages value used if the active path
+// does not belong to a package, e.g. go.mod or a target triggered without file activity.
+const GoAllPackages = "./..."
+
+// goPackage holds the "go list -json" fields used to build the import graph.
+type goPackage struct {
+	Dir          string
+	ImportPath   string
+	GoFiles      []string
+	CgoFiles     []string
+	TestGoFiles  []string
+	XTestGoFiles []string
+	Imports      []string
+	TestImports  []string
+	XTestImports []string
+}
+
+// GoPackageGraph maps the .go files under a root directory to their packages and finds the
+// packages which depend on them, directly or transitively.
+//
+// The graph is loaded from "go list" on first use. Afterward only the packages invalidated by
+// file activity are listed again, unless go.mod or go.sum changes which reloads the whole graph.
+// Those changes are also found by comparing modification times, in case the files are not watched,
+// e.g. if they are outside the root directory or not matched by Target.Include.
+type GoPackageGraph struct {
+	// executor runs "go list".
+	executor cage_exec.Executor
+
+	// root is the absolute path of the directory where "go list ./..." runs.
+	root string
+
+	// mu serializes Resolve calls, which own pkgs, so that only one "go list" runs at a time.
+	mu sync.Mutex
+
+	// pkgs holds the listed packages indexed by goPackage.Dir.
+	pkgs map[string]goPackage
+
+	// modTimes holds the modification times of go.mod and go.sum, from goModTimes, after the whole
+	// graph was last listed.
+	modTimes map[string]time.Time
+
+	// pendingMu guards reload and dirty, which Invalidate updates without waiting for a "go list"
+	// run by Resolve.
+	pendingMu sync.Mutex
+
+	// reload is true until the whole graph is listed and after go.mod/go.sum activity.
+	reload bool
+
+	// dirty holds the directories whose package must be listed again, due to file activity.
+	dirty map[string]bool
+}
+
+// NewGoPackageGraph returns an empty graph of the packages found under the root directory.
+func NewGoPackageGraph(executor cage_exec.Executor, root string) *GoPackageGraph {
+	return &GoPackageGraph{
+		executor: executor,
+		root:     root,
+		pkgs:     map[string]goPackage{},
+		reload:   true,
+		dirty:    map[string]bool{},
+	}
+}
+
+// Invalidate records activity on a path so the graph is updated before the next Resolve.
+//
+// Paths outside the root directory, and files which cannot change the graph, are ignored.
+func (g *GoPackageGraph) Invalidate(path string) {
+	if !g.contains(path) {
+		return
+	}
+
+	g.pendingMu.Lock()
+	defer g.pendingMu.Unlock()
+
+	switch {
+	case filepath.Base(path) == "go.mod" || filepath.Base(path) == "go.sum":
+		g.reload = true
+	case filepath.Ext(path) == ".go":
+		g.dirty[filepath.Dir(path)] = true
+	}
+}
+
+// Resolve returns the import path of the package which contains the .go file, and the import paths
+// of all packages affected by it: the package itself, every package which imports it directly or
+// transitively, and every package whose tests import one of those.
+//
+// If the path does not belong to a package under the root directory, both lists hold GoAllPackages.
+func (g *GoPackageGraph) Resolve(ctx context.Context, path string) (packages []string, affected []string, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	// Take the pending changes so that Invalidate calls during the "go list" runs below are
+	// applied by the next Resolve. If listing fails, the changes are pending again.
+	g.pendingMu.Lock()
+	reload, dirty := g.reload, g.dirty
+	g.reload, g.dirty = false, map[string]bool{}
+	g.pendingMu.Unlock()
+
+	for p, t := range g.goModTimes() {
+		if prev, ok := g.modTimes[p]; !ok || !prev.Equal(t) {
+			reload = true
+		}
+	}
+
+	if err = g.update(ctx, reload, dirty); err != nil {
+		g.pendingMu.Lock()
+		g.reload = g.reload || reload
+		for dir := range dirty {
+			g.dirty[dir] = true
+		}
+		g.pendingMu.Unlock()
+		return nil, nil, errors.WithStack(err)
+	}
+	if reload { // after listing because "go list" may update go.mod itself, e.g. to add a go directive
+		g.modTimes = g.goModTimes()
+	}
+
+	pkg, ok := g.pkgs[filepath.Dir(path)]
+	if filepath.Ext(path) != ".go" || !ok {
+		return []string{GoAllPackages}, []string{GoAllPackages}, nil
+	}
+
+	importers := map[string][]string{}
+	testImporters := map[string][]string{}
+	for _, p := range g.pkgs {
+		for _, i := range p.Imports {
+			importers[i] = append(importers[i], p.ImportPath)
+		}
+		for _, i := range append(append([]string{}, p.TestImports...), p.XTestImports...) {
+			testImporters[i] = append(testImporters[i], p.ImportPath)
+		}
+	}
+
+	// Dependencies of tests are not transitive, so only follow Imports to find the affected
+	// packages, then add the packages whose tests import one of them.
+	found := map[string]bool{pkg.ImportPath: true}
+	queue := []string{pkg.ImportPath}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, i := range importers[cur] {
+			if !found[i] {
+				found[i] = true
+				queue = append(queue, i)
+			}
+		}
+	}
+	for cur := range found {
+		for _, i := range testImporters[cur] {
+			found[i] = true
+		}
+	}
+
+	for i := range found {
+		affected = append(affected, i)
+	}
+	sort.Strings(affected)
+
+	return []string{pkg.ImportPath}, affected, nil
+}
+
+// update lists the whole graph again if reload is true, otherwise only the packages in the dirty
+// directories, and then replaces the affected packages.
+func (g *GoPackageGraph) update(ctx context.Context, reload bool, dirty map[string]bool) error {
+	if reload {
+		pkgs, err := g.list(ctx, GoAllPackages)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		g.pkgs = map[string]goPackage{}
+		for _, p := range pkgs {
+			g.pkgs[p.Dir] = p
+		}
+		return nil
+	}
+
+	if len(dirty) == 0 {
+		return nil
+	}
+
+	var dirs []string
+	for dir := range dirty {
+		if exists, _, _ := cage_file.Exists(dir); exists { // removed dirs are only dropped from the graph
+			rel, err := filepath.Rel(g.root, dir)
+			if err != nil {
+				return errors.Wrapf(err, "failed to get path of [%s] relative to [%s]", dir, g.root)
+			}
+			dirs = append(dirs, "."+string(filepath.Separator)+rel)
+		}
+	}
+
+	var pkgs []goPackage
+	if len(dirs) > 0 {
+		var err error
+		if pkgs, err = g.list(ctx, dirs...); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	for dir := range dirty {
+		delete(g.pkgs, dir)
+	}
+	for _, p := range pkgs {
+		g.pkgs[p.Dir] = p
+	}
+	return nil
+}
+
+// goModTimes returns the modification times of go.mod and go.sum, indexed by path, in the root directory
+// or its nearest ancestor with a go.mod. Missing files have a zero time.
+func (g *GoPackageGraph) goModTimes() map[string]time.Time {
+	dir := g.root
+	for {
+		if exists, _, _ := cage_file.Exists(filepath.Join(dir, "go.mod")); exists {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir { // not in a module, so only detect the creation of the root's go.mod
+			dir = g.root
+			break
+		}
+		dir = parent
+	}
+
+	times := map[string]time.Time{}
+	for _, name := range []string{"go.mod", "go.sum"} {
+		p := filepath.Join(dir, name)
+		times[p] = time.Time{}
+		if exists, fi, _ := cage_file.Exists(p); exists {
+			times[p] = fi.ModTime()
+		}
+	}
+	return times
+}
+
+// contains returns true if the path is the root directory or one of its descendants.
+func (g *GoPackageGraph) contains(path string) bool {
+	rel, err := filepath.Rel(g.root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// list returns the packages, which contain at least one .go file, from "go list -e -json" output.
+func (g *GoPackageGraph) list(ctx context.Context, patterns ...string) (pkgs []goPackage, err error) {
+	cmd := exec.CommandContext(ctx, "go", append([]string{"list", "-e", "-json"}, patterns...)...) // #nosec G204
+	cmd.Dir = g.root
+
+	stdout, stderr, _, err := g.executor.Buffered(ctx, cmd)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list packages in [%s]: %s", g.root, stderr.String())
+	}
+
+	dec := json.NewDecoder(stdout)
+	for {
+		var p goPackage
+		if err = dec.Decode(&p); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "failed to decode package list from [%s]", g.root)
+		}
+		if p.Dir != "" && len(p.GoFiles)+len(p.CgoFiles)+len(p.TestGoFiles)+len(p.XTestGoFiles) > 0 {
+			pkgs = append(pkgs, p)
+		}
+	}
+
+	return pkgs, nil
+}
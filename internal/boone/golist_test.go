@@ -0,0 +1,193 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package boone_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	std_exec "os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/codeactual/boone/internal/boone"
+	cage_exec "github.com/codeactual/boone/internal/cage/os/exec"
+	testkit_file "github.com/codeactual/boone/internal/cage/testkit/os/file"
+)
+
+// blockingExecutor signals each Buffered call on started and waits for release before running it.
+type blockingExecutor struct {
+	cage_exec.CommonExecutor
+
+	started chan struct{}
+	release chan struct{}
+}
+
+func (e blockingExecutor) Buffered(ctx context.Context, cmds ...*std_exec.Cmd) (*bytes.Buffer, *bytes.Buffer, cage_exec.PipelineResult, error) {
+	e.started <- struct{}{}
+	<-e.release
+	return e.CommonExecutor.Buffered(ctx, cmds...)
+}
+
+type GoPackageGraphSuite struct {
+	suite.Suite
+
+	root  string
+	graph *boone.GoPackageGraph
+}
+
+func (suite *GoPackageGraphSuite) SetupTest() {
+	t := suite.T()
+
+	testkit_file.ResetTestdata(t)
+	_, suite.root = testkit_file.CreateDir(t, "gomod")
+
+	// db <- api <- cmd, and web's tests import api.
+	suite.writeFile("go.mod", "module example.com/proj\n")
+	suite.writeFile("db/db.go", "package db\n")
+	suite.writeFile("api/api.go", "package api\n\nimport _ \"example.com/proj/db\"\n")
+	suite.writeFile("cmd/main.go", "package main\n\nimport _ \"example.com/proj/api\"\n\nfunc main() {}\n")
+	suite.writeFile("web/web.go", "package web\n")
+	suite.writeFile("web/web_test.go", "package web_test\n\nimport _ \"example.com/proj/api\"\n")
+	suite.writeFile("doc/README.md", "")
+
+	suite.graph = boone.NewGoPackageGraph(cage_exec.CommonExecutor{}, suite.root)
+}
+
+func (suite *GoPackageGraphSuite) writeFile(name, content string) {
+	t := suite.T()
+	p := filepath.Join(suite.root, name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(p), 0700))
+	require.NoError(t, ioutil.WriteFile(p, []byte(content), 0600))
+}
+
+func (suite *GoPackageGraphSuite) requireResolve(path string, expectedPackages, expectedAffected []string) {
+	t := suite.T()
+	packages, affected, err := suite.graph.Resolve(context.Background(), filepath.Join(suite.root, path))
+	require.NoError(t, err, path)
+	require.Exactly(t, expectedPackages, packages, path)
+	require.Exactly(t, expectedAffected, affected, path)
+}
+
+func (suite *GoPackageGraphSuite) TestResolve() {
+	suite.requireResolve(
+		"db/db.go",
+		[]string{"example.com/proj/db"},
+		[]string{"example.com/proj/api", "example.com/proj/cmd", "example.com/proj/db", "example.com/proj/web"},
+	)
+	suite.requireResolve(
+		"api/api.go",
+		[]string{"example.com/proj/api"},
+		[]string{"example.com/proj/api", "example.com/proj/cmd", "example.com/proj/web"},
+	)
+	suite.requireResolve("cmd/main.go", []string{"example.com/proj/cmd"}, []string{"example.com/proj/cmd"})
+	suite.requireResolve("web/web_test.go", []string{"example.com/proj/web"}, []string{"example.com/proj/web"})
+
+	all := []string{boone.GoAllPackages}
+	suite.requireResolve("go.mod", all, all)
+	suite.requireResolve("doc/README.md", all, all)
+	suite.requireResolve("", all, all)
+}
+
+func (suite *GoPackageGraphSuite) TestInvalidate() {
+	suite.requireResolve("cmd/main.go", []string{"example.com/proj/cmd"}, []string{"example.com/proj/cmd"})
+
+	// The cached graph is used until activity invalidates a package.
+	suite.writeFile("web/web.go", "package web\n\nimport _ \"example.com/proj/cmd/lib\"\n")
+	suite.writeFile("cmd/lib/lib.go", "package lib\n")
+	suite.requireResolve("cmd/lib/lib.go", []string{boone.GoAllPackages}, []string{boone.GoAllPackages})
+
+	suite.graph.Invalidate(filepath.Join(suite.root, "web/web.go"))
+	suite.graph.Invalidate(filepath.Join(suite.root, "cmd/lib/lib.go"))
+	suite.graph.Invalidate("/outside/root/x.go")
+	suite.requireResolve(
+		"cmd/lib/lib.go",
+		[]string{"example.com/proj/cmd/lib"},
+		[]string{"example.com/proj/cmd/lib", "example.com/proj/web"},
+	)
+
+	// Removed packages are dropped from the graph.
+	require.NoError(suite.T(), os.RemoveAll(filepath.Join(suite.root, "cmd/lib")))
+	suite.graph.Invalidate(filepath.Join(suite.root, "cmd/lib/lib.go"))
+	suite.requireResolve("cmd/lib/lib.go", []string{boone.GoAllPackages}, []string{boone.GoAllPackages})
+
+	// go.mod activity reloads the whole graph.
+	suite.writeFile("go.mod", "module example.com/renamed\n")
+	suite.graph.Invalidate(filepath.Join(suite.root, "go.mod"))
+	suite.requireResolve("cmd/main.go", []string{"example.com/renamed/cmd"}, []string{"example.com/renamed/cmd"})
+}
+
+func (suite *GoPackageGraphSuite) TestUnwatchedGoMod() {
+	t := suite.T()
+
+	suite.requireResolve("cmd/main.go", []string{"example.com/proj/cmd"}, []string{"example.com/proj/cmd"})
+
+	// go.mod changes reload the whole graph even without Invalidate, e.g. if it is not watched.
+	suite.writeFile("go.mod", "module example.com/renamed\n")
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(filepath.Join(suite.root, "go.mod"), later, later))
+	suite.requireResolve("cmd/main.go", []string{"example.com/renamed/cmd"}, []string{"example.com/renamed/cmd"})
+
+	// The same applies to the go.mod of a module which contains the root directory.
+	suite.graph = boone.NewGoPackageGraph(cage_exec.CommonExecutor{}, filepath.Join(suite.root, "api"))
+	suite.requireResolve("api/api.go", []string{"example.com/renamed/api"}, []string{"example.com/renamed/api"})
+	suite.writeFile("go.mod", "module example.com/again\n")
+	later = later.Add(time.Minute)
+	require.NoError(t, os.Chtimes(filepath.Join(suite.root, "go.mod"), later, later))
+	suite.requireResolve("api/api.go", []string{"example.com/again/api"}, []string{"example.com/again/api"})
+}
+
+func (suite *GoPackageGraphSuite) TestInvalidateDuringList() {
+	t := suite.T()
+
+	executor := blockingExecutor{started: make(chan struct{}), release: make(chan struct{})}
+	suite.graph = boone.NewGoPackageGraph(executor, suite.root)
+
+	resolved := make(chan struct{})
+	go func() {
+		defer close(resolved)
+		suite.requireResolve("cmd/main.go", []string{"example.com/proj/cmd"}, []string{"example.com/proj/cmd"})
+	}()
+	<-executor.started
+
+	// Activity is recorded while "go list" runs, and applied by the next Resolve.
+	invalidated := make(chan struct{})
+	go func() {
+		suite.graph.Invalidate(filepath.Join(suite.root, "cmd/main.go"))
+		close(invalidated)
+	}()
+	select {
+	case <-invalidated:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Invalidate waited for go list")
+	}
+
+	executor.release <- struct{}{}
+	<-resolved
+
+	listed := make(chan struct{})
+	go func() {
+		<-executor.started
+		close(listed)
+		executor.release <- struct{}{}
+	}()
+	suite.requireResolve("cmd/main.go", []string{"example.com/proj/cmd"}, []string{"example.com/proj/cmd"})
+	select {
+	case <-listed:
+	default:
+		require.FailNow(t, "the invalidated package was not listed again")
+	}
+}
+
+func TestGoPackageGraphSuite(t *testing.T) {
+	suite.Run(t, new(GoPackageGraphSuite))
+}
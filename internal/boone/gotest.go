@@ -0,0 +1,175 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package boone
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// TestPassed is the TestResult.Action of a passing test.
+	TestPassed = "pass"
+
+	// TestFailed is the TestResult.Action of a failing test.
+	TestFailed = "fail"
+
+	// TestSkipped is the TestResult.Action of a skipped test.
+	TestSkipped = "skip"
+)
+
+// TestResult describes the outcome of one test, or package if Test is empty, from "go test -json" output.
+type TestResult struct {
+	// Package is the import path.
+	Package string
+
+	// Test is the name, including the parent names of subtests, e.g. "TestHandler/empty_body".
+	Test string
+
+	// Action is TestPassed, TestFailed, or TestSkipped.
+	Action string
+
+	// Elapsed is the run time in seconds.
+	Elapsed float64
+
+	// Output holds the lines printed by the test, or package, including its "--- FAIL" line.
+	Output string
+}
+
+// Name returns the package and test names, or only the package name for package-level results.
+func (r TestResult) Name() string {
+	if r.Test == "" {
+		return r.Package
+	}
+	return r.Package + " " + r.Test
+}
+
+// goTestEvent is one line of "go test -json" output, https://golang.org/cmd/test2json/.
+type goTestEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Elapsed float64
+	Output  string
+}
+
+// ParseGoTestJSON returns the results found in "go test -json" output, in the order they finished,
+// and the output as "go test" would have printed it without -json.
+//
+// Lines which are not test events, e.g. build errors, are retained in the text.
+func ParseGoTestJSON(output string) (results []TestResult, text string) {
+	var b strings.Builder
+	testOutput := map[string]*strings.Builder{}
+
+	for _, line := range strings.SplitAfter(output, "\n") {
+		if line == "" {
+			continue
+		}
+
+		var event goTestEvent
+		if !strings.HasPrefix(line, "{") || json.Unmarshal([]byte(line), &event) != nil || event.Action == "" {
+			b.WriteString(line)
+			continue
+		}
+
+		key := event.Package + " " + event.Test
+
+		switch event.Action {
+		case "output":
+			b.WriteString(event.Output)
+			if testOutput[key] == nil {
+				testOutput[key] = &strings.Builder{}
+			}
+			testOutput[key].WriteString(event.Output)
+		case TestPassed, TestFailed, TestSkipped:
+			r := TestResult{Package: event.Package, Test: event.Test, Action: event.Action, Elapsed: event.Elapsed}
+			if testOutput[key] != nil {
+				r.Output = testOutput[key].String()
+				delete(testOutput, key)
+			}
+			results = append(results, r)
+		}
+	}
+
+	return results, b.String()
+}
+
+// FailedTestsPattern returns a "go test -run" pattern which matches the top-level tests of the failed
+// results, e.g. "^(TestA|TestB)$", or an empty string if no test failed.
+func FailedTestsPattern(results []TestResult) string {
+	var names []string
+	seen := map[string]bool{}
+
+	for _, r := range results {
+		if r.Action != TestFailed || r.Test == "" {
+			continue
+		}
+		name := strings.SplitN(r.Test, "/", 2)[0]
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, regexp.QuoteMeta(name))
+		}
+	}
+
+	if len(names) == 0 {
+		return ""
+	}
+	return "^(" + strings.Join(names, "|") + ")$"
+}
+
+// TestSummary returns the test counts, e.g. "2 failed, 10 passed, 1 skipped".
+//
+// Package-level results are not counted.
+func TestSummary(results []TestResult) string {
+	count := map[string]int{}
+	for _, r := range results {
+		if r.Test != "" {
+			count[r.Action]++
+		}
+	}
+	return fmt.Sprintf("%d failed, %d passed, %d skipped", count[TestFailed], count[TestPassed], count[TestSkipped])
+}
+
+// FailedTestsText returns the output of each failed test and package, grouped by package.
+func FailedTestsText(results []TestResult) string {
+	var pkgs []string
+	byPkg := map[string][]TestResult{}
+	for _, r := range results {
+		if r.Action != TestFailed {
+			continue
+		}
+		if _, ok := byPkg[r.Package]; !ok {
+			pkgs = append(pkgs, r.Package)
+		}
+		byPkg[r.Package] = append(byPkg[r.Package], r)
+	}
+
+	var b strings.Builder
+	for n, pkg := range pkgs {
+		if n > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(pkg + "\n")
+		for _, r := range byPkg[pkg] {
+			name := r.Test
+			if name == "" {
+				name = "(package)"
+			}
+			fmt.Fprintf(&b, "  FAIL %s (%.2fs)\n", name, r.Elapsed)
+			for _, line := range strings.Split(strings.TrimRight(r.Output, "\n"), "\n") {
+				if line == "" || strings.HasPrefix(line, "=== ") {
+					continue
+				}
+				b.WriteString("    " + line + "\n")
+			}
+		}
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,149 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package boone_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/codeactual/boone/internal/boone"
+)
+
+type GoTestSuite struct {
+	suite.Suite
+
+	output string
+}
+
+func (suite *GoTestSuite) SetupTest() {
+	suite.output = strings.Join([]string{
+		`{"Action":"run","Package":"proj/api","Test":"TestGet"}`,
+		`{"Action":"output","Package":"proj/api","Test":"TestGet","Output":"=== RUN   TestGet\n"}`,
+		`{"Action":"output","Package":"proj/api","Test":"TestGet","Output":"--- PASS: TestGet (0.00s)\n"}`,
+		`{"Action":"pass","Package":"proj/api","Test":"TestGet","Elapsed":0}`,
+		`{"Action":"run","Package":"proj/api","Test":"TestPost"}`,
+		`{"Action":"output","Package":"proj/api","Test":"TestPost","Output":"=== RUN   TestPost\n"}`,
+		`{"Action":"run","Package":"proj/api","Test":"TestPost/empty_body"}`,
+		`{"Action":"output","Package":"proj/api","Test":"TestPost/empty_body","Output":"=== RUN   TestPost/empty_body\n"}`,
+		`{"Action":"output","Package":"proj/api","Test":"TestPost/empty_body","Output":"    api_test.go:31: want 400\n"}`,
+		`{"Action":"output","Package":"proj/api","Test":"TestPost/empty_body","Output":"    --- FAIL: TestPost/empty_body (0.01s)\n"}`,
+		`{"Action":"fail","Package":"proj/api","Test":"TestPost/empty_body","Elapsed":0.01}`,
+		`{"Action":"output","Package":"proj/api","Test":"TestPost","Output":"--- FAIL: TestPost (0.01s)\n"}`,
+		`{"Action":"fail","Package":"proj/api","Test":"TestPost","Elapsed":0.01}`,
+		`{"Action":"run","Package":"proj/api","Test":"TestPut"}`,
+		`{"Action":"output","Package":"proj/api","Test":"TestPut","Output":"--- SKIP: TestPut (0.00s)\n"}`,
+		`{"Action":"skip","Package":"proj/api","Test":"TestPut","Elapsed":0}`,
+		`{"Action":"output","Package":"proj/api","Output":"FAIL\n"}`,
+		`{"Action":"fail","Package":"proj/api","Elapsed":0.02}`,
+		`# proj/cmd`,
+		`cmd/main.go:3:2: undefined: x`,
+		`{"Action":"output","Package":"proj/cmd","Output":"FAIL\tproj/cmd [build failed]\n"}`,
+		`{"Action":"fail","Package":"proj/cmd","Elapsed":0}`,
+		``,
+	}, "\n")
+}
+
+func (suite *GoTestSuite) TestParseGoTestJSON() {
+	t := suite.T()
+
+	results, text := boone.ParseGoTestJSON(suite.output)
+
+	require.Exactly(
+		t,
+		[]boone.TestResult{
+			{Package: "proj/api", Test: "TestGet", Action: boone.TestPassed, Output: "=== RUN   TestGet\n--- PASS: TestGet (0.00s)\n"},
+			{
+				Package: "proj/api", Test: "TestPost/empty_body", Action: boone.TestFailed, Elapsed: 0.01,
+				Output: "=== RUN   TestPost/empty_body\n    api_test.go:31: want 400\n    --- FAIL: TestPost/empty_body (0.01s)\n",
+			},
+			{Package: "proj/api", Test: "TestPost", Action: boone.TestFailed, Elapsed: 0.01, Output: "=== RUN   TestPost\n--- FAIL: TestPost (0.01s)\n"},
+			{Package: "proj/api", Test: "TestPut", Action: boone.TestSkipped, Output: "--- SKIP: TestPut (0.00s)\n"},
+			{Package: "proj/api", Action: boone.TestFailed, Elapsed: 0.02, Output: "FAIL\n"},
+			{Package: "proj/cmd", Action: boone.TestFailed, Output: "FAIL\tproj/cmd [build failed]\n"},
+		},
+		results,
+	)
+
+	require.Exactly(
+		t,
+		strings.Join([]string{
+			"=== RUN   TestGet",
+			"--- PASS: TestGet (0.00s)",
+			"=== RUN   TestPost",
+			"=== RUN   TestPost/empty_body",
+			"    api_test.go:31: want 400",
+			"    --- FAIL: TestPost/empty_body (0.01s)",
+			"--- FAIL: TestPost (0.01s)",
+			"--- SKIP: TestPut (0.00s)",
+			"FAIL",
+			"# proj/cmd",
+			"cmd/main.go:3:2: undefined: x",
+			"FAIL\tproj/cmd [build failed]",
+			"",
+		}, "\n"),
+		text,
+	)
+
+	results, text = boone.ParseGoTestJSON("")
+	require.Empty(t, results)
+	require.Exactly(t, "", text)
+}
+
+func (suite *GoTestSuite) TestFailedTestsPattern() {
+	t := suite.T()
+
+	results, _ := boone.ParseGoTestJSON(suite.output)
+	require.Exactly(t, "^(TestPost)$", boone.FailedTestsPattern(results))
+
+	results = append(results, boone.TestResult{Package: "proj/web", Test: "TestA.B", Action: boone.TestFailed})
+	require.Exactly(t, `^(TestPost|TestA\.B)$`, boone.FailedTestsPattern(results))
+
+	require.Exactly(t, "", boone.FailedTestsPattern(results[:1]))
+	require.Exactly(t, "", boone.FailedTestsPattern(nil))
+}
+
+func (suite *GoTestSuite) TestTestSummary() {
+	t := suite.T()
+
+	results, _ := boone.ParseGoTestJSON(suite.output)
+	require.Exactly(t, "2 failed, 1 passed, 1 skipped", boone.TestSummary(results))
+	require.Exactly(t, "0 failed, 0 passed, 0 skipped", boone.TestSummary(nil))
+}
+
+func (suite *GoTestSuite) TestFailedTestsText() {
+	t := suite.T()
+
+	results, _ := boone.ParseGoTestJSON(suite.output)
+
+	require.Exactly(
+		t,
+		strings.Join([]string{
+			"proj/api",
+			"  FAIL TestPost/empty_body (0.01s)",
+			"        api_test.go:31: want 400",
+			"        --- FAIL: TestPost/empty_body (0.01s)",
+			"  FAIL TestPost (0.01s)",
+			"    --- FAIL: TestPost (0.01s)",
+			"  FAIL (package) (0.02s)",
+			"    FAIL",
+			"",
+			"proj/cmd",
+			"  FAIL (package) (0.00s)",
+			"    FAIL\tproj/cmd [build failed]",
+		}, "\n"),
+		boone.FailedTestsText(results),
+	)
+
+	require.Exactly(t, "", boone.FailedTestsText(results[:1]))
+}
+
+func TestGoTestSuite(t *testing.T) {
+	suite.Run(t, new(GoTestSuite))
+}
@@ -0,0 +1,171 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package boone
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/codeactual/boone/internal/cage/os/file/watcher"
+	cage_filepath "github.com/codeactual/boone/internal/cage/path/filepath"
+)
+
+// HandlerIf defines conditions, based on the activity which triggered the target, which must all be met
+// for a Handler to run. Handlers whose conditions are not met are skipped.
+//
+// Include, Exclude, and Op are ignored if the run has no triggering path, e.g. if it was started
+// by AutoStartTarget or the target browser.
+type HandlerIf struct {
+	// Include holds patterns of which the triggering path must match at least one.
+	//
+	// Glob and Root fields are resolved in the same way as Target.Include.
+	Include []cage_filepath.Glob
+
+	// Exclude holds patterns which the triggering path must not match.
+	//
+	// Glob and Root fields are resolved in the same way as Target.Exclude.
+	Exclude []cage_filepath.Glob
+
+	// Op holds file activity types, e.g. "Create" or "Write", of which the triggering activity must be one.
+	Op []string
+
+	// When is a command which must exit with code 0.
+	//
+	// It supports the same template variables, $VAR expansion, and environment as an Exec.Cmd of
+	// the handler's target, and runs in Target.Root.
+	When string
+
+	// include holds the resolved Include patterns.
+	include []string
+
+	// exclude holds the resolved Exclude patterns.
+	exclude []string
+
+	// when holds the command defined by When.
+	when Exec
+}
+
+// Match returns a description of the first Include, Exclude, or Op condition which the triggering
+// activity does not meet, or an empty string if all are met.
+//
+// When is not evaluated.
+func (i HandlerIf) Match(path, op string) (reason string, err error) {
+	if path == "" {
+		return "", nil
+	}
+
+	if len(i.Op) > 0 {
+		var found bool
+		for _, o := range i.Op {
+			if o == op {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Sprintf("op [%s] is not one of If.Op %v", op, i.Op), nil
+		}
+	}
+
+	if len(i.include) > 0 || len(i.exclude) > 0 {
+		include := i.include
+		if len(include) == 0 {
+			include = []string{string(filepath.Separator) + "**"}
+		}
+		res, matchErr := cage_filepath.PathMatchAny(cage_filepath.MatchAnyInput{
+			Name:    path,
+			Include: include,
+			Exclude: i.exclude,
+		})
+		if matchErr != nil {
+			return "", errors.Wrapf(matchErr, "failed to match If conditions to path [%s]", path)
+		}
+		if res.Exclude != "" {
+			return fmt.Sprintf("path [%s] matches If.Exclude [%s]", path, res.Exclude), nil
+		}
+		if !res.Match {
+			return fmt.Sprintf("path [%s] does not match If.Include", path), nil
+		}
+	}
+
+	return "", nil
+}
+
+// finalizeHandlerIf validates the fields and populates the resolved versions.
+//
+// The input Exec holds the target-level fields which When inherits, e.g. inheritedEnv.
+func finalizeHandlerIf(t *Target, i *HandlerIf, when Exec) (err error) {
+	resolve := func(field string, globs []cage_filepath.Glob) (patterns []string, err error) {
+		for _, g := range globs {
+			root := t.Root
+			if g.Root != "" {
+				if filepath.IsAbs(g.Root) {
+					return nil, errors.Errorf("If.%s root [%s] must be relative to target [Root] field", field, g.Root)
+				}
+				root, err = cage_filepath.Append(t.Root, g.Root)
+				if err != nil {
+					return nil, errors.Wrapf(err, "failed to append If.%s.Root [%s] to Target.Root [%s]", field, g.Root, t.Root)
+				}
+			}
+
+			if g.Pattern == "" {
+				return nil, errors.Errorf("If.%s contains an empty [Glob] field", field)
+			}
+			if filepath.IsAbs(g.Pattern) {
+				return nil, errors.Errorf("If.%s glob [%s] must be relative", field, g.Pattern)
+			}
+
+			pattern, appendErr := cage_filepath.Append(root, g.Pattern)
+			if appendErr != nil {
+				return nil, errors.Wrapf(appendErr, "failed to append If.%s.Pattern [%s] to root [%s]", field, g.Pattern, root)
+			}
+			patterns = append(patterns, pattern)
+		}
+		return patterns, nil
+	}
+
+	if i.include, err = resolve("Include", i.Include); err != nil {
+		return errors.WithStack(err)
+	}
+	if i.exclude, err = resolve("Exclude", i.Exclude); err != nil {
+		return errors.WithStack(err)
+	}
+
+	ops := []watcher.Op{watcher.Create, watcher.Write, watcher.Rename, watcher.Remove}
+	for n, o := range i.Op {
+		var found bool
+		for _, supported := range ops {
+			if strings.EqualFold(o, supported.String()) {
+				i.Op[n] = supported.String()
+				found = true
+				break
+			}
+		}
+		if !found {
+			return errors.Errorf("If.Op [%s] is not one of Create, Write, Rename, Remove", o)
+		}
+	}
+
+	if i.When != "" {
+		when.Cmd = i.When
+		when.Dir = t.Root
+		when.Timeout = DefaultCmdTimeout
+		if when.timeout, err = time.ParseDuration(when.Timeout); err != nil {
+			return errors.Wrapf(err, "failed to parse If.When timeout [%s]", when.Timeout)
+		}
+		if _, err = when.ExpandCmd(CmdTemplateData{}, nil); err != nil {
+			return errors.Wrapf(err, "If.When [%s] is an invalid template", i.When)
+		}
+		i.when = when
+	}
+
+	return nil
+}
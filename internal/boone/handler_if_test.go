@@ -0,0 +1,139 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package boone_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/codeactual/boone/internal/boone"
+	cage_filepath "github.com/codeactual/boone/internal/cage/path/filepath"
+	testkit_file "github.com/codeactual/boone/internal/cage/testkit/os/file"
+)
+
+type HandlerIfSuite struct {
+	suite.Suite
+
+	root string
+}
+
+func (suite *HandlerIfSuite) SetupTest() {
+	t := suite.T()
+	testkit_file.ResetTestdata(t)
+	_, suite.root = testkit_file.CreateDir(t, "target")
+}
+
+// finalizeIf returns the HandlerIf after FinalizeConfig processes it as part of the only handler of a target.
+func (suite *HandlerIfSuite) finalizeIf(cond boone.HandlerIf) (boone.HandlerIf, error) {
+	handler, err := finalizeHandler(suite.root, boone.Handler{Label: "some handler", If: cond, Exec: []boone.Exec{{Cmd: "true"}}})
+	if err != nil {
+		return boone.HandlerIf{}, err
+	}
+	return handler.If, nil
+}
+
+func (suite *HandlerIfSuite) TestMatchDefault() {
+	t := suite.T()
+
+	cond, err := suite.finalizeIf(boone.HandlerIf{})
+	require.NoError(t, err)
+
+	reason, err := cond.Match("/any/path", "Write")
+	require.NoError(t, err)
+	require.Exactly(t, "", reason)
+}
+
+func (suite *HandlerIfSuite) TestMatchPath() {
+	t := suite.T()
+
+	proto := filepath.Join(suite.root, "api", "v1", "user.proto")
+	vendored := filepath.Join(suite.root, "vendor", "dep", "dep.proto")
+	goFile := filepath.Join(suite.root, "user.go")
+
+	cond, err := suite.finalizeIf(boone.HandlerIf{
+		Include: []cage_filepath.Glob{{Pattern: "**/*.proto"}},
+		Exclude: []cage_filepath.Glob{{Pattern: "**", Root: "vendor"}},
+	})
+	require.NoError(t, err)
+
+	reason, err := cond.Match(proto, "Write")
+	require.NoError(t, err)
+	require.Exactly(t, "", reason)
+
+	reason, err = cond.Match(goFile, "Write")
+	require.NoError(t, err)
+	require.Exactly(t, "path ["+goFile+"] does not match If.Include", reason)
+
+	reason, err = cond.Match(vendored, "Write")
+	require.NoError(t, err)
+	require.Exactly(t, "path ["+vendored+"] matches If.Exclude ["+filepath.Join(suite.root, "vendor", "**")+"]", reason)
+
+	// Exclude-only conditions match paths outside Target.Root, e.g. from an upstream target's activity.
+	cond, err = suite.finalizeIf(boone.HandlerIf{Exclude: []cage_filepath.Glob{{Pattern: "**/*_test.go"}}})
+	require.NoError(t, err)
+
+	reason, err = cond.Match("/outside/root/user.go", "Write")
+	require.NoError(t, err)
+	require.Exactly(t, "", reason)
+
+	reason, err = cond.Match(filepath.Join(suite.root, "user_test.go"), "Write")
+	require.NoError(t, err)
+	require.NotEmpty(t, reason)
+}
+
+func (suite *HandlerIfSuite) TestMatchOp() {
+	t := suite.T()
+
+	cond, err := suite.finalizeIf(boone.HandlerIf{Op: []string{"create"}})
+	require.NoError(t, err)
+	require.Exactly(t, []string{"Create"}, cond.Op)
+
+	reason, err := cond.Match("/some/path", "Create")
+	require.NoError(t, err)
+	require.Exactly(t, "", reason)
+
+	reason, err = cond.Match("/some/path", "Write")
+	require.NoError(t, err)
+	require.Exactly(t, "op [Write] is not one of If.Op [Create]", reason)
+}
+
+func (suite *HandlerIfSuite) TestMatchWithoutPath() {
+	t := suite.T()
+
+	cond, err := suite.finalizeIf(boone.HandlerIf{
+		Include: []cage_filepath.Glob{{Pattern: "**/*.proto"}},
+		Op:      []string{"Create"},
+	})
+	require.NoError(t, err)
+
+	reason, err := cond.Match("", "")
+	require.NoError(t, err)
+	require.Exactly(t, "", reason)
+}
+
+func (suite *HandlerIfSuite) TestInvalid() {
+	t := suite.T()
+
+	_, err := suite.finalizeIf(boone.HandlerIf{Op: []string{"Chmod"}})
+	require.Error(t, err)
+
+	_, err = suite.finalizeIf(boone.HandlerIf{Include: []cage_filepath.Glob{{Pattern: ""}}})
+	require.Error(t, err)
+
+	_, err = suite.finalizeIf(boone.HandlerIf{Exclude: []cage_filepath.Glob{{Pattern: "/abs/**"}}})
+	require.Error(t, err)
+
+	_, err = suite.finalizeIf(boone.HandlerIf{When: "test {{.Nope"})
+	require.Error(t, err)
+}
+
+func TestHandlerIfSuite(t *testing.T) {
+	suite.Run(t, new(HandlerIfSuite))
+}
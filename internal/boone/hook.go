@@ -0,0 +1,82 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package boone
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	cage_shell "github.com/codeactual/boone/internal/cage/shell"
+)
+
+const (
+	// HookMarker identifies git hook files written by the "hook install" sub-command so that
+	// "hook uninstall" only removes those, and pre-existing hooks are never overwritten.
+	HookMarker = "# boone hook: managed by `boone hook install`"
+
+	// HookSkipEnv is the environment variable which, if set to a non-empty value, makes
+	// installed hooks allow the commit/push regardless of target statuses.
+	HookSkipEnv = "BOONE_HOOK_SKIP"
+
+	// HookPreCommit selects the git pre-commit hook.
+	HookPreCommit = "pre-commit"
+
+	// HookPrePush selects the git pre-push hook.
+	HookPrePush = "pre-push"
+)
+
+// HookScript returns the content of a git hook file which runs the "hook run" sub-command.
+func HookScript(executable, configPath, hookName string) string {
+	return strings.Join([]string{
+		"#!/bin/sh",
+		HookMarker,
+		fmt.Sprintf("# Set %s=1 to bypass.", HookSkipEnv),
+		fmt.Sprintf("exec %s hook run --config %s %s", cage_shell.Quote(executable), cage_shell.Quote(configPath), hookName),
+		"",
+	}, "\n")
+}
+
+// BlockingStatus returns the statuses which should prevent a commit/push of the input paths.
+//
+// A status is blocking if it is not resolved (failing, debouncing, pending, or running) and its target
+// either matches at least one path, based on Target.MatchPath, or is downstream of such a target.
+func BlockingStatus(targets []Target, statuses []Status, paths []string) (blocking []Status, err error) {
+	covered := map[string]bool{}
+
+	for n := range targets {
+		t := &targets[n]
+		if len(t.Include) == 0 {
+			continue
+		}
+		for _, p := range paths {
+			res, matchErr := t.MatchPath(p)
+			if matchErr != nil {
+				return []Status{}, errors.WithStack(matchErr)
+			}
+			if res.Match {
+				for _, tree := range t.Tree {
+					covered[tree.Id] = true
+				}
+				break
+			}
+		}
+	}
+
+	for _, status := range statuses {
+		if !covered[status.TargetId] {
+			continue
+		}
+		switch status.Cause {
+		case TargetFailed, TargetCanceled, TargetTimedOut, TargetSkipped, TargetDebouncing, TargetPending, TargetResumed, TargetStarted:
+			blocking = append(blocking, status)
+		}
+	}
+
+	return blocking, nil
+}
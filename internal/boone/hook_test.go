@@ -0,0 +1,109 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package boone_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/codeactual/boone/internal/boone"
+	cage_filepath "github.com/codeactual/boone/internal/cage/path/filepath"
+	testkit_file "github.com/codeactual/boone/internal/cage/testkit/os/file"
+)
+
+type HookSuite struct {
+	suite.Suite
+
+	targets []boone.Target
+
+	apiPath string
+	cmdPath string
+	docPath string
+}
+
+func (suite *HookSuite) SetupTest() {
+	t := suite.T()
+
+	testkit_file.ResetTestdata(t)
+	_, root := testkit_file.CreateDir(t, "proj")
+	_, suite.apiPath = testkit_file.CreateFile(t, "proj", "api", "api.go")
+	_, suite.cmdPath = testkit_file.CreateFile(t, "proj", "cmd", "cmd.go")
+	_, suite.docPath = testkit_file.CreateFile(t, "proj", "README.md")
+
+	suite.targets = []boone.Target{
+		{
+			Id:      "api",
+			Label:   "api label",
+			Root:    root,
+			Include: []cage_filepath.Glob{{Pattern: filepath.Join("api", "*.go")}},
+			Handler: []boone.Handler{{Label: "test", Exec: []boone.Exec{{Cmd: "true"}}}},
+		},
+		{
+			Id:       "cmd",
+			Label:    "cmd label",
+			Root:     root,
+			Include:  []cage_filepath.Glob{{Pattern: filepath.Join("cmd", "*.go")}},
+			Upstream: []string{"api"},
+			Handler:  []boone.Handler{{Label: "test", Exec: []boone.Exec{{Cmd: "true"}}}},
+		},
+	}
+
+	all := []*boone.Target{&suite.targets[0], &suite.targets[1]}
+	require.NoError(t, boone.FinalizeConfig(all, &boone.Config{}))
+}
+
+func (suite *HookSuite) TestBlockingStatus() {
+	t := suite.T()
+
+	apiFailed := boone.Status{TargetId: "api", Cause: boone.TargetFailed}
+	cmdPending := boone.Status{TargetId: "cmd", Cause: boone.TargetPending}
+	cmdStarted := boone.Status{TargetId: "cmd", Cause: boone.TargetStarted}
+
+	cases := []struct {
+		id       string
+		statuses []boone.Status
+		paths    []string
+		expected []boone.Status
+	}{
+		{
+			id:       "matched target failed",
+			statuses: []boone.Status{apiFailed},
+			paths:    []string{suite.apiPath},
+			expected: []boone.Status{apiFailed},
+		},
+		{
+			id:       "downstream of matched target is unfinished",
+			statuses: []boone.Status{cmdPending},
+			paths:    []string{suite.apiPath},
+			expected: []boone.Status{cmdPending},
+		},
+		{
+			id:       "upstream of matched target is not considered",
+			statuses: []boone.Status{apiFailed, cmdStarted},
+			paths:    []string{suite.cmdPath},
+			expected: []boone.Status{cmdStarted},
+		},
+		{
+			id:       "no target matched",
+			statuses: []boone.Status{apiFailed, cmdPending},
+			paths:    []string{suite.docPath},
+		},
+	}
+
+	for _, c := range cases {
+		actual, err := boone.BlockingStatus(suite.targets, c.statuses, c.paths)
+		require.NoError(t, err, c.id)
+		require.Exactly(t, c.expected, actual, c.id)
+	}
+}
+
+func TestHookSuite(t *testing.T) {
+	suite.Run(t, new(HookSuite))
+}
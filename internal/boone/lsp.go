@@ -0,0 +1,627 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package boone
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	cage_zap "github.com/codeactual/boone/internal/cage/log/zap"
+)
+
+const (
+	// LSPPollInterval is how often LSPServer reads the status list.
+	LSPPollInterval = time.Second
+
+	// LSPCommandRerun is the workspace/executeCommand command which reruns the target in its first argument.
+	LSPCommandRerun = "boone.rerunTarget"
+
+	// LSPCommandShowOutput is the workspace/executeCommand command which opens the full output of the
+	// target in its first argument.
+	LSPCommandShowOutput = "boone.showOutput"
+
+	// LSPSource is the prefix of each LSPDiagnostic.Source value.
+	LSPSource = "boone"
+
+	// LSP severities, https://microsoft.github.io/language-server-protocol/specification#diagnostic
+	LSPSeverityError       = 1
+	LSPSeverityWarning     = 2
+	LSPSeverityInformation = 3
+	LSPSeverityHint        = 4
+
+	// JSON-RPC error codes, https://microsoft.github.io/language-server-protocol/specification#responseMessage
+	lspErrInvalidParams   = -32602
+	lspErrMethodNotFound  = -32601
+	lspErrRequestFailed   = -32803
+	lspErrServerNotInited = -32002
+)
+
+// lspOutputFilePattern matches characters replaced in target Ids to form LSPCommandShowOutput file names.
+var lspOutputFilePattern = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// LSPPosition is a zero-based line and character offset.
+type LSPPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// LSPRange is a document span whose End is exclusive.
+type LSPRange struct {
+	Start LSPPosition `json:"start"`
+	End   LSPPosition `json:"end"`
+}
+
+// LSPDiagnostic is published for each Status.Diagnostics item of a failing target.
+type LSPDiagnostic struct {
+	Range    LSPRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+
+	// Data holds the Target.Id, e.g. for clients which send diagnostics back in code action requests.
+	Data LSPDiagnosticData `json:"data"`
+}
+
+// LSPDiagnosticData identifies the source of an LSPDiagnostic.
+type LSPDiagnosticData struct {
+	TargetId string `json:"targetId"`
+}
+
+// lspMessage holds the fields of any request, notification, or response received from the client.
+type lspMessage struct {
+	Id     *json.RawMessage `json:"id,omitempty"`
+	Method string           `json:"method,omitempty"`
+	Params json.RawMessage  `json:"params,omitempty"`
+}
+
+type lspResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	Id      *json.RawMessage `json:"id"`
+	Result  interface{}      `json:"result"`
+}
+
+type lspErrorResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	Id      *json.RawMessage `json:"id"`
+	Error   lspError         `json:"error"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type lspOutgoing struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Id      *int        `json:"id,omitempty"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+// LSPCommand is sent back by the client in a workspace/executeCommand request.
+type LSPCommand struct {
+	Title     string        `json:"title"`
+	Command   string        `json:"command"`
+	Arguments []interface{} `json:"arguments"`
+}
+
+// LSPCodeAction is offered for diagnostics of failing targets.
+type LSPCodeAction struct {
+	Title       string          `json:"title"`
+	Kind        string          `json:"kind"`
+	Diagnostics []LSPDiagnostic `json:"diagnostics"`
+	Command     LSPCommand      `json:"command"`
+}
+
+// LSPServer is a Language Server Protocol front-end, run by the "lsp" sub-command over stdio, which
+// publishes the diagnostics of failing targets and offers code actions to rerun them or show their output.
+//
+// It polls the status list from a running instance if Data.Control.File is configured and accepting
+// connections, otherwise from Data.Session.File.
+type LSPServer struct {
+	// OutputDir is where LSPCommandShowOutput writes output files.
+	//
+	// It defaults to a "boone-lsp" directory in os.TempDir.
+	OutputDir string
+
+	// log receives debug/info-level messages.
+	log *zap.Logger
+
+	// cfg selects where to read the status list and send rerun requests.
+	cfg Config
+
+	// in receives client messages.
+	in *bufio.Reader
+
+	// out receives server messages.
+	out io.Writer
+
+	// outMu serializes writes to out.
+	outMu sync.Mutex
+
+	// mu guards the fields below.
+	mu sync.Mutex
+
+	// initialized is true after the "initialize" request.
+	initialized bool
+
+	// shutdown is true after the "shutdown" request.
+	shutdown bool
+
+	// showDocument is true if the client supports "window/showDocument" requests.
+	showDocument bool
+
+	// failing holds the failing statuses of the last poll.
+	failing []Status
+
+	// published holds the JSON-encoded diagnostics last published for each document URI.
+	published map[string]string
+
+	// nextId is the Id of the next request sent to the client.
+	nextId int
+}
+
+// NewLSPServer returns a server which reads client messages from in and writes server messages to out.
+func NewLSPServer(log *zap.Logger, cfg Config, in io.Reader, out io.Writer) *LSPServer {
+	return &LSPServer{
+		OutputDir: filepath.Join(os.TempDir(), "boone-lsp"),
+		log:       log,
+		cfg:       cfg,
+		in:        bufio.NewReader(in),
+		out:       out,
+		published: map[string]string{},
+	}
+}
+
+// Serve handles client messages until the "exit" notification or the end of input.
+//
+// Diagnostics are published every LSPPollInterval after the "initialized" notification.
+func (s *LSPServer) Serve(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for {
+		body, err := s.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		var msg lspMessage
+		if err = json.Unmarshal(body, &msg); err != nil {
+			s.log.Error("failed to decode LSP message", cage_zap.Tag("lsp"), zap.Error(err))
+			continue
+		}
+
+		s.log.Debug("LSP message", cage_zap.Tag("lsp"), zap.String("method", msg.Method))
+
+		switch msg.Method {
+		case "": // response to a server request, e.g. window/showDocument
+		case "initialized":
+			go s.poll(ctx)
+		case "exit":
+			s.mu.Lock()
+			shutdown := s.shutdown
+			s.mu.Unlock()
+			if !shutdown {
+				return errors.New("received exit notification before shutdown request")
+			}
+			return nil
+		default:
+			if msg.Id == nil {
+				continue // unsupported notification
+			}
+			result, resErr := s.handle(msg)
+			if resErr != nil {
+				err = s.write(lspErrorResponse{JSONRPC: "2.0", Id: msg.Id, Error: *resErr})
+			} else {
+				err = s.write(lspResponse{JSONRPC: "2.0", Id: msg.Id, Result: result})
+			}
+			if err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	}
+}
+
+// Refresh reads the status list and publishes diagnostics for every document whose diagnostics changed,
+// including empty lists for documents whose targets no longer fail.
+func (s *LSPServer) Refresh() error {
+	session, _, err := ReadSession(s.cfg)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	failing := NewStatusSummary(session.Statuses).Failing
+	diags := LSPDiagnostics(failing)
+
+	s.mu.Lock()
+	s.failing = failing
+	var changed []string
+	for uri, d := range diags {
+		b, _ := json.Marshal(d)
+		if s.published[uri] != string(b) {
+			s.published[uri] = string(b)
+			changed = append(changed, uri)
+		}
+	}
+	for uri := range s.published {
+		if _, ok := diags[uri]; !ok {
+			delete(s.published, uri)
+			changed = append(changed, uri)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Strings(changed)
+	for _, uri := range changed {
+		d := diags[uri]
+		if d == nil {
+			d = []LSPDiagnostic{}
+		}
+		err = s.notify("textDocument/publishDiagnostics", map[string]interface{}{"uri": uri, "diagnostics": d})
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	return nil
+}
+
+// poll calls Refresh every LSPPollInterval until the context is done.
+func (s *LSPServer) poll(ctx context.Context) {
+	ticker := time.NewTicker(LSPPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Refresh(); err != nil {
+			s.log.Error("failed to refresh LSP diagnostics", cage_zap.Tag("lsp"), zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// handle returns the result of a client request.
+func (s *LSPServer) handle(msg lspMessage) (interface{}, *lspError) {
+	s.mu.Lock()
+	initialized := s.initialized
+	s.mu.Unlock()
+
+	if !initialized && msg.Method != "initialize" {
+		return nil, &lspError{Code: lspErrServerNotInited, Message: "server is not initialized"}
+	}
+
+	switch msg.Method {
+	case "initialize":
+		var params struct {
+			Capabilities struct {
+				Window struct {
+					ShowDocument struct {
+						Support bool `json:"support"`
+					} `json:"showDocument"`
+				} `json:"window"`
+			} `json:"capabilities"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &lspError{Code: lspErrInvalidParams, Message: err.Error()}
+		}
+		s.mu.Lock()
+		s.initialized = true
+		s.showDocument = params.Capabilities.Window.ShowDocument.Support
+		s.mu.Unlock()
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"codeActionProvider": true,
+				"executeCommandProvider": map[string]interface{}{
+					"commands": []string{LSPCommandRerun, LSPCommandShowOutput},
+				},
+			},
+			"serverInfo": map[string]string{"name": "boone"},
+		}, nil
+	case "shutdown":
+		s.mu.Lock()
+		s.shutdown = true
+		s.mu.Unlock()
+		return nil, nil
+	case "textDocument/codeAction":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			Range LSPRange `json:"range"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &lspError{Code: lspErrInvalidParams, Message: err.Error()}
+		}
+		s.mu.Lock()
+		failing := s.failing
+		s.mu.Unlock()
+		return LSPCodeActions(failing, params.TextDocument.URI, params.Range), nil
+	case "workspace/executeCommand":
+		var params struct {
+			Command   string   `json:"command"`
+			Arguments []string `json:"arguments"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &lspError{Code: lspErrInvalidParams, Message: err.Error()}
+		}
+		if len(params.Arguments) != 1 {
+			return nil, &lspError{Code: lspErrInvalidParams, Message: "expected one argument: the target Id"}
+		}
+		var err error
+		switch params.Command {
+		case LSPCommandRerun:
+			err = s.rerun(params.Arguments[0])
+		case LSPCommandShowOutput:
+			err = s.showOutput(params.Arguments[0])
+		default:
+			return nil, &lspError{Code: lspErrInvalidParams, Message: "unsupported command [" + params.Command + "]"}
+		}
+		if err != nil {
+			return nil, &lspError{Code: lspErrRequestFailed, Message: err.Error()}
+		}
+		return nil, nil
+	}
+
+	return nil, &lspError{Code: lspErrMethodNotFound, Message: "unsupported method [" + msg.Method + "]"}
+}
+
+// rerun asks the running instance to run the target again.
+func (s *LSPServer) rerun(targetId string) error {
+	if s.cfg.Data.Control.File == "" {
+		return errors.New("rerun requires a running instance with [Data.Control.File] configured")
+	}
+	_, err := SendControlRequest(s.cfg.Data.Control.File, ControlRequest{Op: ControlOpRerun, TargetId: targetId})
+	return errors.WithStack(err)
+}
+
+// showOutput writes the full output of the failing target to a file in OutputDir and asks the client
+// to open it, or if unsupported, to display the file path.
+func (s *LSPServer) showOutput(targetId string) error {
+	s.mu.Lock()
+	failing := s.failing
+	showDocument := s.showDocument
+	s.mu.Unlock()
+
+	var status Status
+	var found bool
+	for _, f := range failing {
+		if f.TargetId == targetId {
+			status, found = f, true
+			break
+		}
+	}
+	if !found {
+		return errors.Errorf("target with Id [%s] is not failing", targetId)
+	}
+
+	if err := os.MkdirAll(s.OutputDir, dataDirPerm); err != nil {
+		return errors.Wrapf(err, "failed to create output dir [%s]", s.OutputDir)
+	}
+	name := filepath.Join(s.OutputDir, lspOutputFilePattern.ReplaceAllString(targetId, "_")+".log")
+	if err := ioutil.WriteFile(name, []byte(LSPOutputText(status)), dataFilePerm); err != nil {
+		return errors.Wrapf(err, "failed to write output file [%s]", name)
+	}
+
+	if showDocument {
+		s.mu.Lock()
+		s.nextId++
+		id := s.nextId
+		s.mu.Unlock()
+		return errors.WithStack(s.write(lspOutgoing{
+			JSONRPC: "2.0",
+			Id:      &id,
+			Method:  "window/showDocument",
+			Params:  map[string]interface{}{"uri": lspFileURI(name), "takeFocus": true},
+		}))
+	}
+
+	return errors.WithStack(s.notify("window/showMessage", map[string]interface{}{
+		"type":    LSPSeverityInformation,
+		"message": fmt.Sprintf("boone: output of [%s] was written to %s", status.TargetLabel, name),
+	}))
+}
+
+// notify sends a notification to the client.
+func (s *LSPServer) notify(method string, params interface{}) error {
+	return s.write(lspOutgoing{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// read returns the body of the next client message.
+func (s *LSPServer) read() ([]byte, error) {
+	length := -1
+	for {
+		line, err := s.in.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && line == "" {
+				return nil, io.EOF
+			}
+			return nil, errors.Wrap(err, "failed to read LSP message header")
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		if strings.HasPrefix(strings.ToLower(line), "content-length:") {
+			length, err = strconv.Atoi(strings.TrimSpace(line[len("content-length:"):]))
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to parse LSP header [%s]", line)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("LSP message is missing a Content-Length header")
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(s.in, body); err != nil {
+		return nil, errors.Wrap(err, "failed to read LSP message body")
+	}
+	return body, nil
+}
+
+// write sends a message to the client.
+func (s *LSPServer) write(msg interface{}) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode LSP message")
+	}
+
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+
+	if _, err = fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body); err != nil {
+		return errors.Wrap(err, "failed to write LSP message")
+	}
+	return nil
+}
+
+// LSPDiagnostics returns the diagnostics of the statuses indexed by document URI.
+//
+// Relative Diagnostic.File values are resolved from Status.Dir.
+func LSPDiagnostics(statuses []Status) map[string][]LSPDiagnostic {
+	diags := map[string][]LSPDiagnostic{}
+
+	for _, status := range statuses {
+		for _, d := range status.Diagnostics {
+			uri := lspFileURI(lspDiagnosticFile(status, d))
+			diags[uri] = append(diags[uri], lspDiagnostic(status, d))
+		}
+	}
+
+	return diags
+}
+
+// LSPCodeActions returns the "rerun target" and "show full output" actions of each failing target with
+// a diagnostic in the document range.
+func LSPCodeActions(statuses []Status, uri string, r LSPRange) []LSPCodeAction {
+	actions := []LSPCodeAction{}
+
+	for _, status := range statuses {
+		var inRange []LSPDiagnostic
+		for _, d := range status.Diagnostics {
+			if lspFileURI(lspDiagnosticFile(status, d)) != uri {
+				continue
+			}
+			ld := lspDiagnostic(status, d)
+			if ld.Range.Start.Line < r.Start.Line || ld.Range.Start.Line > r.End.Line {
+				continue
+			}
+			inRange = append(inRange, ld)
+		}
+		if len(inRange) == 0 {
+			continue
+		}
+
+		actions = append(
+			actions,
+			LSPCodeAction{
+				Title:       fmt.Sprintf("boone: rerun target [%s]", status.TargetLabel),
+				Kind:        "quickfix",
+				Diagnostics: inRange,
+				Command: LSPCommand{
+					Title:     "Rerun target",
+					Command:   LSPCommandRerun,
+					Arguments: []interface{}{status.TargetId},
+				},
+			},
+			LSPCodeAction{
+				Title:       fmt.Sprintf("boone: show full output of [%s]", status.TargetLabel),
+				Kind:        "quickfix",
+				Diagnostics: inRange,
+				Command: LSPCommand{
+					Title:     "Show full output",
+					Command:   LSPCommandShowOutput,
+					Arguments: []interface{}{status.TargetId},
+				},
+			},
+		)
+	}
+
+	return actions
+}
+
+// LSPOutputText returns the content of the file opened by LSPCommandShowOutput.
+func LSPOutputText(status Status) string {
+	return ansiPattern.ReplaceAllString(fmt.Sprintf(
+		"Target: %s\nHandler: %s\nCommand: %s\nDir: %s\nError: %s\n\n--- stderr ---\n%s\n--- stdout ---\n%s\n",
+		status.TargetLabel, status.HandlerLabel, status.Cmd, status.Dir, status.Err, status.Stderr, status.Stdout,
+	), "")
+}
+
+// lspDiagnostic converts one of the status's diagnostics.
+func lspDiagnostic(status Status, d Diagnostic) LSPDiagnostic {
+	return LSPDiagnostic{
+		Range:    lspDiagnosticRange(d),
+		Severity: lspSeverity(d.Severity),
+		Source:   fmt.Sprintf("%s (%s)", LSPSource, status.TargetLabel),
+		Message:  d.Message,
+		Data:     LSPDiagnosticData{TargetId: status.TargetId},
+	}
+}
+
+// lspDiagnosticFile returns the absolute path of the diagnostic's file.
+func lspDiagnosticFile(status Status, d Diagnostic) string {
+	if filepath.IsAbs(d.File) || status.Dir == "" {
+		return d.File
+	}
+	return filepath.Join(status.Dir, d.File)
+}
+
+// lspDiagnosticRange converts the 1-based location. The range extends to the end of the line, from the
+// column if known, so that editors underline it instead of only displaying a caret.
+func lspDiagnosticRange(d Diagnostic) LSPRange {
+	line := d.Line - 1
+	if line < 0 {
+		line = 0
+	}
+	var character int
+	if d.Column > 0 {
+		character = d.Column - 1
+	}
+	return LSPRange{Start: LSPPosition{Line: line, Character: character}, End: LSPPosition{Line: line + 1}}
+}
+
+// lspSeverity converts a Diagnostic.Severity value.
+func lspSeverity(severity string) int {
+	switch severity {
+	case "warning":
+		return LSPSeverityWarning
+	case "info":
+		return LSPSeverityInformation
+	case "note", "hint":
+		return LSPSeverityHint
+	}
+	return LSPSeverityError
+}
+
+// lspFileURI returns the "file" URI of the absolute path.
+func lspFileURI(name string) string {
+	return (&url.URL{Scheme: "file", Path: name}).String()
+}
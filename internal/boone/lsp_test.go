@@ -0,0 +1,324 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package boone_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/codeactual/boone/internal/boone"
+	cage_gob "github.com/codeactual/boone/internal/cage/encoding/gob"
+	"github.com/codeactual/boone/internal/cage/testkit"
+	testkit_file "github.com/codeactual/boone/internal/cage/testkit/os/file"
+)
+
+type LSPSuite struct {
+	suite.Suite
+
+	cfg boone.Config
+
+	session boone.Session
+
+	// clientOut sends client messages to the server.
+	clientOut *io.PipeWriter
+
+	// clientIn receives server messages.
+	clientIn *bufio.Reader
+
+	// serveErr receives the Serve return value.
+	serveErr chan error
+
+	server *boone.LSPServer
+}
+
+func (suite *LSPSuite) SetupTest() {
+	t := suite.T()
+
+	testkit_file.ResetTestdata(t)
+	dataDir := testkit_file.DynamicDataDirAbs(t)
+
+	suite.cfg = boone.Config{
+		Data: boone.DataConfig{
+			Control: boone.ControlConfig{File: filepath.Join(dataDir, "boone.sock")},
+			Session: boone.SessionConfig{File: filepath.Join(dataDir, "session")},
+		},
+		Target: []boone.Target{
+			{Id: "api", Label: "api label", Tree: []boone.TargetTree{{Id: "api", Label: "api label"}}},
+		},
+	}
+
+	suite.session = boone.Session{
+		Version: boone.SessionVersion,
+		Statuses: []boone.Status{
+			{
+				TargetId:     "api",
+				TargetLabel:  "api label",
+				HandlerLabel: "test",
+				Cause:        boone.TargetFailed,
+				Dir:          "/proj/api",
+				Path:         "/proj/api/handler.go",
+				Op:           "WRITE",
+				Err:          "exit status 1",
+				Stderr:       "handler.go:12:5: undefined: x\n",
+				Diagnostics: []boone.Diagnostic{
+					{File: "handler.go", Line: 12, Column: 5, Severity: "error", Message: "undefined: x"},
+					{File: "/proj/api/log.go", Line: 3, Severity: "warning", Message: "unused"},
+				},
+			},
+			{TargetId: "cmd", TargetLabel: "cmd label", Cause: boone.TargetStarted},
+		},
+	}
+}
+
+func (suite *LSPSuite) TearDownTest() {
+	if suite.clientOut != nil {
+		_ = suite.clientOut.Close()
+		suite.clientOut = nil
+	}
+}
+
+// start runs the server in the background and completes the initialization handshake.
+func (suite *LSPSuite) start(capabilities string) {
+	t := suite.T()
+
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	suite.clientOut = clientOut
+	suite.clientIn = bufio.NewReader(clientIn)
+	serveErr := make(chan error, 1)
+	suite.serveErr = serveErr
+
+	server := boone.NewLSPServer(testkit.NewZapLogger(), suite.cfg, serverIn, serverOut)
+	server.OutputDir = testkit_file.DynamicDataDirAbs(t)
+	suite.server = server
+	go func() {
+		serveErr <- server.Serve(context.Background())
+		_ = serverOut.Close()
+	}()
+
+	suite.send(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":` + capabilities + `}}`)
+	res := suite.recv()
+	require.Exactly(t, float64(1), res["id"])
+	require.Exactly(
+		t,
+		[]interface{}{boone.LSPCommandRerun, boone.LSPCommandShowOutput},
+		res["result"].(map[string]interface{})["capabilities"].(map[string]interface{})["executeCommandProvider"].(map[string]interface{})["commands"],
+	)
+
+	suite.send(`{"jsonrpc":"2.0","method":"initialized","params":{}}`)
+}
+
+func (suite *LSPSuite) send(body string) {
+	_, err := fmt.Fprintf(suite.clientOut, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	require.NoError(suite.T(), err)
+}
+
+func (suite *LSPSuite) recv() (msg map[string]interface{}) {
+	t := suite.T()
+
+	var length int
+	for {
+		line, err := suite.clientIn.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		length, err = strconv.Atoi(strings.TrimPrefix(line, "Content-Length: "))
+		require.NoError(t, err)
+	}
+
+	body := make([]byte, length)
+	_, err := io.ReadFull(suite.clientIn, body)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(body, &msg))
+
+	return msg
+}
+
+func (suite *LSPSuite) TestDiagnostics() {
+	t := suite.T()
+
+	diags := boone.LSPDiagnostics(suite.session.Statuses)
+
+	require.Exactly(
+		t,
+		map[string][]boone.LSPDiagnostic{
+			"file:///proj/api/handler.go": {
+				{
+					Range:    boone.LSPRange{Start: boone.LSPPosition{Line: 11, Character: 4}, End: boone.LSPPosition{Line: 12}},
+					Severity: boone.LSPSeverityError,
+					Source:   "boone (api label)",
+					Message:  "undefined: x",
+					Data:     boone.LSPDiagnosticData{TargetId: "api"},
+				},
+			},
+			"file:///proj/api/log.go": {
+				{
+					Range:    boone.LSPRange{Start: boone.LSPPosition{Line: 2}, End: boone.LSPPosition{Line: 3}},
+					Severity: boone.LSPSeverityWarning,
+					Source:   "boone (api label)",
+					Message:  "unused",
+					Data:     boone.LSPDiagnosticData{TargetId: "api"},
+				},
+			},
+		},
+		diags,
+	)
+}
+
+func (suite *LSPSuite) TestCodeActions() {
+	t := suite.T()
+
+	onLine := boone.LSPRange{Start: boone.LSPPosition{Line: 11}, End: boone.LSPPosition{Line: 11, Character: 10}}
+	actions := boone.LSPCodeActions(suite.session.Statuses, "file:///proj/api/handler.go", onLine)
+	require.Len(t, actions, 2)
+	require.Exactly(t, boone.LSPCommandRerun, actions[0].Command.Command)
+	require.Exactly(t, []interface{}{"api"}, actions[0].Command.Arguments)
+	require.Exactly(t, boone.LSPCommandShowOutput, actions[1].Command.Command)
+	require.Len(t, actions[0].Diagnostics, 1)
+
+	otherLine := boone.LSPRange{Start: boone.LSPPosition{Line: 20}, End: boone.LSPPosition{Line: 20}}
+	require.Empty(t, boone.LSPCodeActions(suite.session.Statuses, "file:///proj/api/handler.go", otherLine))
+	require.Empty(t, boone.LSPCodeActions(suite.session.Statuses, "file:///proj/api/other.go", onLine))
+}
+
+func (suite *LSPSuite) TestPublishAndClear() {
+	t := suite.T()
+
+	require.NoError(t, cage_gob.EncodeToFile(suite.cfg.Data.Session.File, suite.session))
+
+	suite.start(`{}`)
+
+	for _, uri := range []string{"file:///proj/api/handler.go", "file:///proj/api/log.go"} {
+		msg := suite.recv()
+		require.Exactly(t, "textDocument/publishDiagnostics", msg["method"])
+		params := msg["params"].(map[string]interface{})
+		require.Exactly(t, uri, params["uri"])
+		require.Len(t, params["diagnostics"], 1)
+	}
+
+	// The target passed and was removed from the status list.
+	require.NoError(t, cage_gob.EncodeToFile(suite.cfg.Data.Session.File, boone.Session{Statuses: suite.session.Statuses[1:]}))
+
+	for _, uri := range []string{"file:///proj/api/handler.go", "file:///proj/api/log.go"} {
+		msg := suite.recv()
+		require.Exactly(t, "textDocument/publishDiagnostics", msg["method"])
+		params := msg["params"].(map[string]interface{})
+		require.Exactly(t, uri, params["uri"])
+		require.Empty(t, params["diagnostics"])
+	}
+
+	suite.send(`{"jsonrpc":"2.0","id":2,"method":"shutdown"}`)
+	res := suite.recv()
+	require.Exactly(t, float64(2), res["id"])
+	require.Contains(t, res, "result")
+
+	suite.send(`{"jsonrpc":"2.0","method":"exit"}`)
+	require.NoError(t, <-suite.serveErr)
+}
+
+func (suite *LSPSuite) TestExecuteCommand() {
+	t := suite.T()
+
+	control, err := boone.NewControlServer(testkit.NewZapLogger(), suite.cfg.Data.Control.File)
+	require.NoError(t, err)
+	go control.Start()
+	defer control.Stop()
+
+	execReqCh := make(chan boone.ExecRequest, 1)
+	control.SetSession(suite.session)
+	control.EnableRerun(suite.cfg.Target, execReqCh)
+
+	suite.start(`{}`)
+	suite.recv() // handler.go diagnostics
+	suite.recv() // log.go diagnostics
+
+	suite.send(`{"jsonrpc":"2.0","id":2,"method":"textDocument/codeAction","params":{"textDocument":{"uri":"file:///proj/api/handler.go"},"range":{"start":{"line":11,"character":0},"end":{"line":11,"character":0}},"context":{"diagnostics":[]}}}`)
+	res := suite.recv()
+	require.Len(t, res["result"], 2)
+
+	suite.send(`{"jsonrpc":"2.0","id":3,"method":"workspace/executeCommand","params":{"command":"` + boone.LSPCommandRerun + `","arguments":["api"]}}`)
+	res = suite.recv()
+	require.Exactly(t, float64(3), res["id"])
+	require.NotContains(t, res, "error")
+
+	select {
+	case req := <-execReqCh:
+		require.Exactly(t, boone.ExecCauseControl, req.Cause)
+		require.Exactly(t, "api", req.TargetId)
+		require.Exactly(t, "/proj/api/handler.go", req.Event.Path)
+		require.Exactly(t, suite.cfg.Target[0].Tree, req.Tree)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "rerun request was not received")
+	}
+
+	suite.send(`{"jsonrpc":"2.0","id":4,"method":"workspace/executeCommand","params":{"command":"` + boone.LSPCommandShowOutput + `","arguments":["api"]}}`)
+	msg := suite.recv()
+	require.Exactly(t, "window/showMessage", msg["method"])
+	res = suite.recv()
+	require.Exactly(t, float64(4), res["id"])
+
+	b, err := ioutil.ReadFile(filepath.Join(suite.server.OutputDir, "api.log"))
+	require.NoError(t, err)
+	require.Exactly(t, boone.LSPOutputText(suite.session.Statuses[0]), string(b))
+
+	suite.send(`{"jsonrpc":"2.0","id":5,"method":"workspace/executeCommand","params":{"command":"` + boone.LSPCommandRerun + `","arguments":["unknown"]}}`)
+	res = suite.recv()
+	require.Contains(t, res, "error")
+}
+
+func (suite *LSPSuite) TestShowDocument() {
+	t := suite.T()
+
+	require.NoError(t, cage_gob.EncodeToFile(suite.cfg.Data.Session.File, suite.session))
+
+	suite.start(`{"window":{"showDocument":{"support":true}}}`)
+	suite.recv() // handler.go diagnostics
+	suite.recv() // log.go diagnostics
+
+	suite.send(`{"jsonrpc":"2.0","id":2,"method":"workspace/executeCommand","params":{"command":"` + boone.LSPCommandShowOutput + `","arguments":["api"]}}`)
+	msg := suite.recv()
+	require.Exactly(t, "window/showDocument", msg["method"])
+	require.Exactly(t, "file://"+filepath.Join(suite.server.OutputDir, "api.log"), msg["params"].(map[string]interface{})["uri"])
+	res := suite.recv()
+	require.Exactly(t, float64(2), res["id"])
+}
+
+func (suite *LSPSuite) TestUninitialized() {
+	t := suite.T()
+
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	suite.clientOut = clientOut
+	suite.clientIn = bufio.NewReader(clientIn)
+
+	server := boone.NewLSPServer(testkit.NewZapLogger(), suite.cfg, serverIn, serverOut)
+	go func() {
+		_ = server.Serve(context.Background())
+	}()
+
+	suite.send(`{"jsonrpc":"2.0","id":1,"method":"textDocument/codeAction","params":{}}`)
+	res := suite.recv()
+	require.Contains(t, res, "error")
+}
+
+func TestLSPSuite(t *testing.T) {
+	suite.Run(t, new(LSPSuite))
+}
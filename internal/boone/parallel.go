@@ -0,0 +1,85 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package boone
+
+import (
+	"github.com/pkg/errors"
+)
+
+// handlerResult describes how one handler's commands finished during a target run.
+type handlerResult struct {
+	// status is from the handler's first command which did not pass, with a TargetFailed, TargetCanceled,
+	// TargetTimedOut, or TargetWarned cause.
+	//
+	// It is nil if all commands passed or the handler did not run, except when the handler did not run
+	// because the tree was canceled or a timeout was reached before it started.
+	status *Status
+
+	// execTimeout describes the Exec.Timeout which the command of a TargetTimedOut status reached.
+	//
+	// It is empty if the Target.Timeout or GlobalConfig.TreeTimeout was reached instead.
+	execTimeout string
+
+	// flaky holds one TargetPass.Flaky description per command which only passed after a retry.
+	flaky []string
+
+	// skipped describes why the handler's Handler.If was not met.
+	skipped string
+
+	// blocked is true if the handler did not run because one of its dependencies failed or the tree
+	// was canceled.
+	blocked bool
+}
+
+// ok returns true if handlers which depend on this one may run.
+func (r handlerResult) ok() bool {
+	if r.blocked {
+		return false
+	}
+	return r.status == nil || r.status.Cause == TargetWarned
+}
+
+// finalizeHandlerDeps validates the Needs fields of the target's handlers and populates their deps.
+//
+// A handler depends on:
+//
+//   - the handlers selected by Needs, if configured
+//   - otherwise, if Parallel is true, all earlier handlers except the Parallel ones declared directly before it
+//   - otherwise, all earlier handlers
+func finalizeHandlerDeps(t *Target) error {
+	for h := range t.Handler {
+		handler := &t.Handler[h]
+		handler.deps = nil
+
+		if len(handler.Needs) > 0 {
+			for _, label := range handler.Needs {
+				var found bool
+				for earlier := 0; earlier < h; earlier++ {
+					if t.Handler[earlier].Label == label {
+						handler.deps = append(handler.deps, earlier)
+						found = true
+					}
+				}
+				if !found {
+					return errors.Errorf("handler [%s] Needs [%s] which is not the label of a handler declared before it", handler.Label, label)
+				}
+			}
+			continue
+		}
+
+		last := h
+		if handler.Parallel {
+			for last > 0 && t.Handler[last-1].Parallel && len(t.Handler[last-1].Needs) == 0 {
+				last--
+			}
+		}
+		for earlier := 0; earlier < last; earlier++ {
+			handler.deps = append(handler.deps, earlier)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package boone_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/codeactual/boone/internal/boone"
+	testkit_file "github.com/codeactual/boone/internal/cage/testkit/os/file"
+)
+
+type ParallelSuite struct {
+	suite.Suite
+
+	root string
+}
+
+func (suite *ParallelSuite) SetupTest() {
+	t := suite.T()
+	testkit_file.ResetTestdata(t)
+	_, suite.root = testkit_file.CreateDir(t, "target")
+}
+
+// run dispatches the handlers as the only target and returns the message sent after it finishes.
+func (suite *ParallelSuite) run(handlers ...boone.Handler) (pass *boone.TargetPass, fail *boone.Status) {
+	target := &boone.Target{Label: "some target", Id: "some target", Root: suite.root, Handler: handlers}
+	run := runTree(suite.T(), &boone.Dispatcher{}, target)
+	if len(run.passes) > 0 {
+		return &run.passes[0], nil
+	}
+	return nil, &run.fails[0]
+}
+
+func (suite *ParallelSuite) TestParallel() {
+	t := suite.T()
+
+	// The first handler only passes if the second one runs before the first one finishes.
+	first := shHandler("wait for b", "for i in $(seq 100); do [ -f b ] && exit 0; sleep 0.05; done; exit 1")
+	first.Parallel = true
+	second := shHandler("create b", "touch b")
+	second.Parallel = true
+	last := shHandler("after both", "touch c")
+
+	pass, fail := suite.run(first, second, last)
+	require.Nil(t, fail)
+	require.NotNil(t, pass)
+	requireFile(t, suite.root, "c", true)
+}
+
+func (suite *ParallelSuite) TestEveryFailure() {
+	t := suite.T()
+
+	lint := shHandler("lint", "echo lint failed >&2; exit 3")
+	lint.Parallel = true
+	vet := shHandler("vet", "echo vet failed >&2; exit 4")
+	vet.Parallel = true
+	build := shHandler("build", "touch build")
+	build.Parallel = true
+	report := shHandler("report", "touch report")
+	report.Needs = []string{"build"}
+	deploy := shHandler("deploy", "touch deploy")
+	deploy.Needs = []string{"vet"}
+
+	pass, fail := suite.run(lint, vet, build, report, deploy)
+	require.Nil(t, pass)
+	require.NotNil(t, fail)
+
+	require.Exactly(t, boone.TargetFailed, fail.Cause)
+	require.Exactly(t, "lint", fail.HandlerLabel)
+	require.Exactly(t, "lint failed\n", fail.Stderr)
+	require.Len(t, fail.Failures, 1)
+	require.Exactly(t, "vet", fail.Failures[0].HandlerLabel)
+	require.Exactly(t, "vet failed\n", fail.Failures[0].Stderr)
+
+	requireFile(t, suite.root, "build", true)
+	requireFile(t, suite.root, "report", true) // its only dependency passed
+	requireFile(t, suite.root, "deploy", false)
+
+	summary := boone.NewStatusSummary([]boone.Status{*fail})
+	require.Len(t, summary.Failing, 2)
+}
+
+func (suite *ParallelSuite) TestSerialByDefault() {
+	t := suite.T()
+
+	pass, fail := suite.run(shHandler("first", "exit 1"), shHandler("second", "touch second"))
+	require.Nil(t, pass)
+	require.NotNil(t, fail)
+	require.Exactly(t, "first", fail.HandlerLabel)
+	require.Empty(t, fail.Failures)
+	requireFile(t, suite.root, "second", false)
+}
+
+func (suite *ParallelSuite) TestNeedsInvalid() {
+	t := suite.T()
+
+	newTarget := func(handlers ...boone.Handler) []*boone.Target {
+		return []*boone.Target{{Label: "some target", Root: suite.root, Handler: handlers}}
+	}
+
+	later := shHandler("later", "true")
+	first := shHandler("first", "true")
+	first.Needs = []string{"later"}
+	require.Error(t, boone.FinalizeConfig(newTarget(first, later), &boone.Config{}))
+
+	missing := shHandler("missing", "true")
+	missing.Needs = []string{"nope"}
+	require.Error(t, boone.FinalizeConfig(newTarget(shHandler("some", "true"), missing), &boone.Config{}))
+}
+
+func TestParallelSuite(t *testing.T) {
+	suite.Run(t, new(ParallelSuite))
+}
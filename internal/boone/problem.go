@@ -0,0 +1,245 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package boone
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultProblemSeverity is the default ProblemMatcher.Severity value.
+	DefaultProblemSeverity = "error"
+
+	// ProblemPresetGeneric matches "file:line:col: message" lines with an optional severity before the message.
+	ProblemPresetGeneric = "generic"
+
+	// ProblemPresetGo matches Go compiler errors.
+	ProblemPresetGo = "go"
+
+	// ProblemPresetGoTest matches failure messages logged by Go tests, and compiler errors.
+	ProblemPresetGoTest = "gotest"
+
+	// ProblemPresetGoVet matches go vet reports.
+	ProblemPresetGoVet = "govet"
+
+	// ProblemPresetGolangciLint matches golangci-lint issues in its default output format.
+	ProblemPresetGolangciLint = "golangci-lint"
+)
+
+// ProblemPresets holds the patterns of each built-in ProblemMatcher.Preset.
+var ProblemPresets = map[string][]string{
+	ProblemPresetGeneric: {
+		`^(?P<file>[^\s:]+):(?P<line>\d+):(?P<column>\d+):\s*(?:(?P<severity>(?i:error|warning|info|note))\s*:\s*)?(?P<message>.+)$`,
+	},
+	ProblemPresetGo: {
+		`^(?P<file>[^\s:]+\.go):(?P<line>\d+):(?P<column>\d+): (?P<message>.+)$`,
+	},
+	ProblemPresetGoTest: {
+		`^\s+(?P<file>[^\s:]+_test\.go):(?P<line>\d+): (?P<message>.+)$`,
+		`^(?P<file>[^\s:]+\.go):(?P<line>\d+):(?P<column>\d+): (?P<message>.+)$`,
+	},
+	ProblemPresetGoVet: {
+		`^(?:vet: )?(?P<file>[^\s:]+\.go):(?P<line>\d+):(?P<column>\d+): (?P<message>.+)$`,
+	},
+	ProblemPresetGolangciLint: {
+		`^(?P<file>[^\s:]+\.go):(?P<line>\d+)(?::(?P<column>\d+))?: (?P<message>.+)$`,
+	},
+}
+
+// ansiPattern matches ANSI escape sequences, which are removed from output before matching.
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]`)
+
+// ProblemMatcher defines how to extract Diagnostic values from command output.
+type ProblemMatcher struct {
+	// Preset selects built-in patterns, e.g. ProblemPresetGo, instead of Pattern.
+	Preset string
+
+	// Pattern is a regular expression matched against each output line.
+	//
+	// It must include the named groups "file" and "message", and may include "line", "column", and "severity".
+	Pattern string
+
+	// Severity is used when Pattern has no "severity" group or it did not match.
+	//
+	// It defaults to DefaultProblemSeverity.
+	Severity string
+
+	// re is the compiled version of Pattern.
+	re *regexp.Regexp
+}
+
+// Diagnostic describes one problem, e.g. a compiler error, found in command output.
+type Diagnostic struct {
+	// File is the path as it appeared in the output, and may be relative to the command's working directory.
+	File string
+
+	// Line is 1-based, or 0 if unknown.
+	Line int
+
+	// Column is 1-based, or 0 if unknown.
+	Column int
+
+	// Severity is a lowercase value such as "error" or "warning".
+	Severity string
+
+	// Message is the problem description.
+	Message string
+}
+
+// String returns the conventional "file:line:col: severity: message" format.
+func (d Diagnostic) String() string {
+	loc := d.File
+	if d.Line > 0 {
+		loc += ":" + strconv.Itoa(d.Line)
+		if d.Column > 0 {
+			loc += ":" + strconv.Itoa(d.Column)
+		}
+	}
+	return fmt.Sprintf("%s: %s: %s", loc, d.Severity, d.Message)
+}
+
+// CompileProblemMatchers returns a copy of the matchers, with each Preset expanded into one matcher per
+// built-in pattern, and all patterns compiled.
+func CompileProblemMatchers(matchers []ProblemMatcher) (compiled []ProblemMatcher, err error) {
+	for _, m := range matchers {
+		if m.Severity == "" {
+			m.Severity = DefaultProblemSeverity
+		}
+
+		var patterns []string
+		if m.Preset == "" {
+			patterns = []string{m.Pattern}
+		} else {
+			if m.Pattern != "" {
+				return []ProblemMatcher{}, errors.Errorf("problem matcher with Preset [%s] must not also have a Pattern", m.Preset)
+			}
+			var ok bool
+			patterns, ok = ProblemPresets[m.Preset]
+			if !ok {
+				return []ProblemMatcher{}, errors.Errorf("problem matcher Preset [%s] is not supported", m.Preset)
+			}
+		}
+
+		for _, p := range patterns {
+			c := m
+			c.Pattern = p
+
+			c.re, err = regexp.Compile(p)
+			if err != nil {
+				return []ProblemMatcher{}, errors.Wrapf(err, "failed to compile problem matcher Pattern [%s]", p)
+			}
+
+			var hasFile, hasMessage bool
+			for _, name := range c.re.SubexpNames() {
+				hasFile = hasFile || name == "file"
+				hasMessage = hasMessage || name == "message"
+			}
+			if !hasFile || !hasMessage {
+				return []ProblemMatcher{}, errors.Errorf("problem matcher Pattern [%s] must have named groups [file] and [message]", p)
+			}
+
+			compiled = append(compiled, c)
+		}
+	}
+	return compiled, nil
+}
+
+// ParseDiagnostics returns the unique problems found in the output by the compiled matchers.
+//
+// Each line is matched by the first matcher which accepts it.
+func ParseDiagnostics(matchers []ProblemMatcher, output string) (diags []Diagnostic) {
+	if len(matchers) == 0 {
+		return diags
+	}
+
+	seen := map[Diagnostic]bool{}
+
+	for _, line := range strings.Split(ansiPattern.ReplaceAllString(output, ""), "\n") {
+		line = strings.TrimRight(line, "\r")
+
+		for _, m := range matchers {
+			if m.re == nil {
+				continue
+			}
+			match := m.re.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+
+			d := Diagnostic{Severity: m.Severity}
+			for n, name := range m.re.SubexpNames() {
+				switch name {
+				case "file":
+					d.File = match[n]
+				case "line":
+					d.Line, _ = strconv.Atoi(match[n])
+				case "column":
+					d.Column, _ = strconv.Atoi(match[n])
+				case "severity":
+					if match[n] != "" {
+						d.Severity = strings.ToLower(match[n])
+					}
+				case "message":
+					d.Message = strings.TrimSpace(match[n])
+				}
+			}
+
+			if !seen[d] {
+				seen[d] = true
+				diags = append(diags, d)
+			}
+			break
+		}
+	}
+
+	return diags
+}
+
+// DiagnosticSummary returns a short description of the problems, e.g. "3 errors in api/handler.go"
+// or "2 errors, 1 warning in api/handler.go and 1 other file".
+func DiagnosticSummary(diags []Diagnostic) string {
+	if len(diags) == 0 {
+		return ""
+	}
+
+	var severities []string
+	severityCount := map[string]int{}
+	fileSeen := map[string]bool{}
+	for _, d := range diags {
+		if severityCount[d.Severity] == 0 {
+			severities = append(severities, d.Severity)
+		}
+		severityCount[d.Severity]++
+		fileSeen[d.File] = true
+	}
+
+	var counts []string
+	for _, s := range severities {
+		n := severityCount[s]
+		if n == 1 || (s != "error" && s != "warning") {
+			counts = append(counts, fmt.Sprintf("%d %s", n, s))
+		} else {
+			counts = append(counts, fmt.Sprintf("%d %ss", n, s))
+		}
+	}
+
+	summary := strings.Join(counts, ", ") + " in " + diags[0].File
+	switch others := len(fileSeen) - 1; others {
+	case 0:
+	case 1:
+		summary += " and 1 other file"
+	default:
+		summary += fmt.Sprintf(" and %d other files", others)
+	}
+
+	return summary
+}
@@ -0,0 +1,173 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package boone_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/codeactual/boone/internal/boone"
+)
+
+type ProblemSuite struct {
+	suite.Suite
+}
+
+func (suite *ProblemSuite) TestParseDiagnosticsPreset() {
+	t := suite.T()
+
+	cases := []struct {
+		preset   string
+		output   []string
+		expected []boone.Diagnostic
+	}{
+		{
+			preset: boone.ProblemPresetGo,
+			output: []string{
+				"# github.com/some/proj/api",
+				"api/handler.go:12:5: undefined: x",
+				"api/handler.go:20:2: imported and not used: \"fmt\"",
+			},
+			expected: []boone.Diagnostic{
+				{File: "api/handler.go", Line: 12, Column: 5, Severity: "error", Message: "undefined: x"},
+				{File: "api/handler.go", Line: 20, Column: 2, Severity: "error", Message: "imported and not used: \"fmt\""},
+			},
+		},
+		{
+			preset: boone.ProblemPresetGoTest,
+			output: []string{
+				"--- FAIL: TestHandler (0.00s)",
+				"    handler_test.go:31: expected 200, got 500",
+				"FAIL",
+				"FAIL\tgithub.com/some/proj/api\t0.012s",
+				"\x1b[31mapi/handler.go:12:5: undefined: x\x1b[0m",
+			},
+			expected: []boone.Diagnostic{
+				{File: "handler_test.go", Line: 31, Severity: "error", Message: "expected 200, got 500"},
+				{File: "api/handler.go", Line: 12, Column: 5, Severity: "error", Message: "undefined: x"},
+			},
+		},
+		{
+			preset: boone.ProblemPresetGoVet,
+			output: []string{
+				"# github.com/some/proj/api",
+				"vet: api/handler.go:7:2: undeclared name: y",
+				"api/log.go:9:3: Printf format %d has arg s of wrong type string",
+			},
+			expected: []boone.Diagnostic{
+				{File: "api/handler.go", Line: 7, Column: 2, Severity: "error", Message: "undeclared name: y"},
+				{File: "api/log.go", Line: 9, Column: 3, Severity: "error", Message: "Printf format %d has arg s of wrong type string"},
+			},
+		},
+		{
+			preset: boone.ProblemPresetGolangciLint,
+			output: []string{
+				"api/handler.go:12:5: Error return value is not checked (errcheck)",
+				"\t_ = f()",
+				"\t    ^",
+				"api/log.go:4: File is not `gofmt`-ed (gofmt)",
+			},
+			expected: []boone.Diagnostic{
+				{File: "api/handler.go", Line: 12, Column: 5, Severity: "error", Message: "Error return value is not checked (errcheck)"},
+				{File: "api/log.go", Line: 4, Severity: "error", Message: "File is not `gofmt`-ed (gofmt)"},
+			},
+		},
+		{
+			preset: boone.ProblemPresetGeneric,
+			output: []string{
+				"src/index.ts:3:14: warning: unused variable",
+				"src/index.ts:3:14: warning: unused variable", // duplicate
+				"src/app.ts:10:1: missing semicolon",
+				"no location here",
+			},
+			expected: []boone.Diagnostic{
+				{File: "src/index.ts", Line: 3, Column: 14, Severity: "warning", Message: "unused variable"},
+				{File: "src/app.ts", Line: 10, Column: 1, Severity: "error", Message: "missing semicolon"},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		matchers, err := boone.CompileProblemMatchers([]boone.ProblemMatcher{{Preset: c.preset}})
+		require.NoError(t, err, c.preset)
+		require.Exactly(t, c.expected, boone.ParseDiagnostics(matchers, strings.Join(c.output, "\n")), c.preset)
+	}
+}
+
+func (suite *ProblemSuite) TestParseDiagnosticsPattern() {
+	t := suite.T()
+
+	matchers, err := boone.CompileProblemMatchers([]boone.ProblemMatcher{
+		{Pattern: `^(?P<severity>E|W) (?P<file>\S+) line (?P<line>\d+): (?P<message>.+)$`, Severity: "warning"},
+	})
+	require.NoError(t, err)
+
+	require.Exactly(
+		t,
+		[]boone.Diagnostic{
+			{File: "a.py", Line: 3, Severity: "e", Message: "bad indent"},
+		},
+		boone.ParseDiagnostics(matchers, "E a.py line 3: bad indent\nok"),
+	)
+}
+
+func (suite *ProblemSuite) TestCompileProblemMatchersError() {
+	t := suite.T()
+
+	cases := map[string]boone.ProblemMatcher{
+		"unknown preset":          {Preset: "unknown"},
+		"preset and pattern":      {Preset: boone.ProblemPresetGo, Pattern: `(?P<file>.+):(?P<message>.+)`},
+		"invalid pattern":         {Pattern: `(?P<file>`},
+		"missing required groups": {Pattern: `^(?P<file>\S+)$`},
+	}
+
+	for id, m := range cases {
+		_, err := boone.CompileProblemMatchers([]boone.ProblemMatcher{m})
+		require.Error(t, err, id)
+	}
+}
+
+func (suite *ProblemSuite) TestDiagnosticSummary() {
+	t := suite.T()
+
+	require.Exactly(t, "", boone.DiagnosticSummary(nil))
+	require.Exactly(
+		t,
+		"1 error in api/handler.go",
+		boone.DiagnosticSummary([]boone.Diagnostic{{File: "api/handler.go", Severity: "error"}}),
+	)
+	require.Exactly(
+		t,
+		"3 errors in api/handler.go",
+		boone.DiagnosticSummary([]boone.Diagnostic{
+			{File: "api/handler.go", Severity: "error"},
+			{File: "api/handler.go", Severity: "error"},
+			{File: "api/handler.go", Severity: "error"},
+		}),
+	)
+	require.Exactly(
+		t,
+		"2 errors, 1 warning in api/handler.go and 2 other files",
+		boone.DiagnosticSummary([]boone.Diagnostic{
+			{File: "api/handler.go", Severity: "error"},
+			{File: "api/log.go", Severity: "warning"},
+			{File: "api/db.go", Severity: "error"},
+		}),
+	)
+	require.Exactly(
+		t,
+		"api/handler.go:12:5: error: undefined: x",
+		boone.Diagnostic{File: "api/handler.go", Line: 12, Column: 5, Severity: "error", Message: "undefined: x"}.String(),
+	)
+}
+
+func TestProblemSuite(t *testing.T) {
+	suite.Run(t, new(ProblemSuite))
+}
@@ -0,0 +1,88 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package boone
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// QuickfixFormatEntries is the QuickfixConfig.Format which writes one "file:line:col: severity: message"
+	// line per Status.Diagnostics item, readable by vim's default 'errorformat'.
+	//
+	// Failures without diagnostics are written as one line which describes the target and error.
+	QuickfixFormatEntries = "entries"
+
+	// QuickfixFormatOutput is the QuickfixConfig.Format which writes the standard error and output of each
+	// failure, for use with :cfile and a custom 'errorformat'.
+	//
+	// Each failure's output is wrapped in "Entering/Leaving directory" lines, which vim's default
+	// 'errorformat' uses to resolve relative paths from Status.Dir.
+	QuickfixFormatOutput = "output"
+
+	// DefaultQuickfixFormat is the default QuickfixConfig.Format value.
+	DefaultQuickfixFormat = QuickfixFormatEntries
+)
+
+// QuickfixText returns the quickfix file content which lists the failing statuses.
+func QuickfixText(statuses []Status, format string) string {
+	var b strings.Builder
+
+	for _, status := range NewStatusSummary(statuses).Failing {
+		switch format {
+		case QuickfixFormatOutput:
+			if status.Cause == TargetSkipped { // no output or directory to report
+				continue
+			}
+			output := ansiPattern.ReplaceAllString(status.Stderr+status.Stdout, "")
+			if output != "" && !strings.HasSuffix(output, "\n") {
+				output += "\n"
+			}
+			fmt.Fprintf(&b, "boone: Entering directory '%s'\n", status.Dir)
+			b.WriteString(output)
+			fmt.Fprintf(&b, "boone: Leaving directory '%s'\n", status.Dir)
+		default:
+			if len(status.Diagnostics) == 0 {
+				fmt.Fprintf(&b, "%s | %s | %s: %s\n", status.TargetLabel, status.HandlerLabel, status.Cause, status.Err)
+				continue
+			}
+			for _, d := range status.Diagnostics {
+				if !filepath.IsAbs(d.File) && status.Dir != "" {
+					d.File = filepath.Join(status.Dir, d.File)
+				}
+				b.WriteString(d.String() + "\n")
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// WriteQuickfixFile replaces the content of Data.Quickfix.File, if configured, based on the failing statuses.
+//
+// The content is written to a temporary file first and then renamed, so an editor never reads a partial list.
+func WriteQuickfixFile(c QuickfixConfig, statuses []Status) error {
+	if c.File == "" {
+		return nil
+	}
+
+	tmp := c.File + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(QuickfixText(statuses, c.Format)), dataFilePerm); err != nil {
+		return errors.Wrapf(err, "failed to write quickfix file [%s]", tmp)
+	}
+	if err := os.Rename(tmp, c.File); err != nil {
+		return errors.Wrapf(err, "failed to rename quickfix file [%s] to [%s]", tmp, c.File)
+	}
+
+	return nil
+}
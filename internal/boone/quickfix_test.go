@@ -0,0 +1,104 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package boone_test
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/codeactual/boone/internal/boone"
+	testkit_file "github.com/codeactual/boone/internal/cage/testkit/os/file"
+)
+
+type QuickfixSuite struct {
+	suite.Suite
+
+	statuses []boone.Status
+}
+
+func (suite *QuickfixSuite) SetupTest() {
+	suite.statuses = []boone.Status{
+		{
+			TargetLabel:  "api",
+			HandlerLabel: "test",
+			Cause:        boone.TargetFailed,
+			Dir:          "/proj/api",
+			Err:          "exit status 1",
+			Stderr:       "\x1b[31mhandler.go:12:5: undefined: x\x1b[0m\n",
+			Stdout:       "FAIL",
+			Diagnostics: []boone.Diagnostic{
+				{File: "handler.go", Line: 12, Column: 5, Severity: "error", Message: "undefined: x"},
+				{File: "/other/log.go", Line: 3, Severity: "warning", Message: "unused"},
+			},
+		},
+		{TargetLabel: "cmd", HandlerLabel: "build", Cause: boone.TargetStarted, Dir: "/proj/cmd"},
+		{TargetLabel: "doc", HandlerLabel: "lint", Cause: boone.TargetCanceled, Dir: "/proj/doc", Err: "context canceled"},
+	}
+}
+
+func (suite *QuickfixSuite) TestQuickfixTextEntries() {
+	t := suite.T()
+
+	require.Exactly(
+		t,
+		strings.Join([]string{
+			"/proj/api/handler.go:12:5: error: undefined: x",
+			"/other/log.go:3: warning: unused",
+			"doc | lint | canceled: context canceled",
+			"",
+		}, "\n"),
+		boone.QuickfixText(suite.statuses, boone.QuickfixFormatEntries),
+	)
+}
+
+func (suite *QuickfixSuite) TestQuickfixTextOutput() {
+	t := suite.T()
+
+	require.Exactly(
+		t,
+		strings.Join([]string{
+			"boone: Entering directory '/proj/api'",
+			"handler.go:12:5: undefined: x",
+			"FAIL",
+			"boone: Leaving directory '/proj/api'",
+			"boone: Entering directory '/proj/doc'",
+			"boone: Leaving directory '/proj/doc'",
+			"",
+		}, "\n"),
+		boone.QuickfixText(suite.statuses, boone.QuickfixFormatOutput),
+	)
+}
+
+func (suite *QuickfixSuite) TestWriteQuickfixFile() {
+	t := suite.T()
+
+	testkit_file.ResetTestdata(t)
+	_, name := testkit_file.CreateFile(t, "quickfix")
+
+	cfg := boone.QuickfixConfig{File: name, Format: boone.QuickfixFormatEntries}
+
+	require.NoError(t, boone.WriteQuickfixFile(cfg, suite.statuses))
+	b, err := ioutil.ReadFile(name)
+	require.NoError(t, err)
+	require.Exactly(t, boone.QuickfixText(suite.statuses, cfg.Format), string(b))
+
+	// Passing targets are removed from the status list.
+	require.NoError(t, boone.WriteQuickfixFile(cfg, suite.statuses[1:2]))
+	b, err = ioutil.ReadFile(name)
+	require.NoError(t, err)
+	require.Exactly(t, "", string(b))
+
+	require.NoError(t, boone.WriteQuickfixFile(boone.QuickfixConfig{}, suite.statuses))
+}
+
+func TestQuickfixSuite(t *testing.T) {
+	suite.Run(t, new(QuickfixSuite))
+}
@@ -0,0 +1,129 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package boone
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultRetryBackoff is the Retry.Backoff value used if one is not configured.
+	DefaultRetryBackoff = "1s"
+
+	// MaxRetryDelay is the longest wait between attempts, which ends the doubling of Retry.Backoff.
+	MaxRetryDelay = time.Hour
+)
+
+// Retry defines whether to run a failed command again, e.g. an integration test which fails
+// intermittently, before its failure is reported.
+type Retry struct {
+	// Count is how many more times the command may run after its first failure.
+	//
+	// If zero, failures are not retried.
+	Count int
+
+	// Backoff is a time.Duration compatible string which selects how long to wait before the first
+	// retry. The wait doubles before each later retry, up to MaxRetryDelay.
+	//
+	// It defaults to DefaultRetryBackoff if Count is non-zero. It must not exceed MaxRetryDelay.
+	Backoff string
+
+	// Code optionally limits retries to failures with one of the exit codes.
+	Code []int
+
+	// Output optionally limits retries to failures whose standard output or error matches the regular expression.
+	Output string
+
+	// backoff is the parsed version of Backoff.
+	backoff time.Duration
+
+	// output is the compiled version of Output.
+	output *regexp.Regexp
+}
+
+// Attempts returns the maximum number of times the command may run.
+func (r Retry) Attempts() int {
+	return 1 + r.Count
+}
+
+// Match returns true if the failure, from the last attempt's exit code and output, satisfies
+// the Code and Output filters.
+//
+// If both filters are configured, both must match.
+func (r Retry) Match(code int, stdout, stderr string) bool {
+	if len(r.Code) > 0 {
+		var found bool
+		for _, c := range r.Code {
+			if c == code {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if r.output != nil && !r.output.MatchString(stdout) && !r.output.MatchString(stderr) {
+		return false
+	}
+	return true
+}
+
+// Delay returns how long to wait after the failed attempt, starting at 1, before the next one.
+func (r Retry) Delay(attempt int) time.Duration {
+	delay := r.backoff
+	for n := 1; n < attempt && delay < MaxRetryDelay; n++ {
+		delay *= 2
+	}
+	if delay > MaxRetryDelay {
+		return MaxRetryDelay
+	}
+	return delay
+}
+
+// AttemptText returns a description such as "attempt 2/3", or an empty string if the command
+// is not configured to retry.
+func AttemptText(attempt, attempts int) string {
+	if attempts < 2 {
+		return ""
+	}
+	return fmt.Sprintf("attempt %d/%d", attempt, attempts)
+}
+
+// finalizeRetry validates the fields and populates the parsed/compiled versions.
+func finalizeRetry(r *Retry) error {
+	if r.Count < 0 {
+		return errors.Errorf("Retry.Count [%d] must not be negative", r.Count)
+	}
+
+	if r.Count > 0 && r.Backoff == "" {
+		r.Backoff = DefaultRetryBackoff
+	}
+	var err error
+	if r.Backoff != "" {
+		r.backoff, err = time.ParseDuration(r.Backoff)
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse Retry.Backoff [%s]", r.Backoff)
+		}
+		if r.backoff < 0 || r.backoff > MaxRetryDelay {
+			return errors.Errorf("Retry.Backoff [%s] must be between 0 and [%s]", r.Backoff, MaxRetryDelay)
+		}
+	}
+
+	if r.Output != "" {
+		r.output, err = regexp.Compile(r.Output)
+		if err != nil {
+			return errors.Wrapf(err, "failed to compile Retry.Output [%s]", r.Output)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,138 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package boone_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/codeactual/boone/internal/boone"
+	testkit_file "github.com/codeactual/boone/internal/cage/testkit/os/file"
+	testkit_time "github.com/codeactual/boone/internal/cage/testkit/time"
+)
+
+type RetrySuite struct {
+	suite.Suite
+}
+
+func (suite *RetrySuite) SetupTest() {
+	testkit_file.ResetTestdata(suite.T())
+}
+
+// finalizeRetry returns the Retry after FinalizeConfig processes it as part of the only command of a target.
+func (suite *RetrySuite) finalizeRetry(retry boone.Retry) (boone.Retry, error) {
+	exe, err := finalizeExec(boone.Exec{Cmd: "true", Retry: retry})
+	if err != nil {
+		return boone.Retry{}, err
+	}
+	return exe.Retry, nil
+}
+
+func (suite *RetrySuite) TestMatch() {
+	t := suite.T()
+
+	retry, err := suite.finalizeRetry(boone.Retry{Count: 2})
+	require.NoError(t, err)
+	require.True(t, retry.Match(1, "", ""))
+	require.True(t, retry.Match(-1, "", ""))
+
+	retry, err = suite.finalizeRetry(boone.Retry{Count: 2, Code: []int{2, 3}})
+	require.NoError(t, err)
+	require.False(t, retry.Match(1, "", ""))
+	require.True(t, retry.Match(3, "", ""))
+
+	retry, err = suite.finalizeRetry(boone.Retry{Count: 2, Output: `connection (refused|reset)`})
+	require.NoError(t, err)
+	require.False(t, retry.Match(1, "FAIL", "assertion failed"))
+	require.True(t, retry.Match(1, "dial tcp: connection refused", ""))
+	require.True(t, retry.Match(1, "", "read: connection reset"))
+
+	retry, err = suite.finalizeRetry(boone.Retry{Count: 2, Code: []int{1}, Output: `timeout`})
+	require.NoError(t, err)
+	require.False(t, retry.Match(2, "timeout", ""))
+	require.False(t, retry.Match(1, "", ""))
+	require.True(t, retry.Match(1, "", "i/o timeout"))
+}
+
+func (suite *RetrySuite) TestDelay() {
+	t := suite.T()
+
+	retry, err := suite.finalizeRetry(boone.Retry{Count: 3})
+	require.NoError(t, err)
+	require.Exactly(t, boone.DefaultRetryBackoff, retry.Backoff)
+	require.Exactly(t, 4, retry.Attempts())
+	require.Exactly(t, time.Second, retry.Delay(1))
+
+	retry, err = suite.finalizeRetry(boone.Retry{Count: 3, Backoff: "250ms"})
+	require.NoError(t, err)
+	require.Exactly(t, 250*time.Millisecond, retry.Delay(1))
+	require.Exactly(t, 500*time.Millisecond, retry.Delay(2))
+	require.Exactly(t, time.Second, retry.Delay(3))
+
+	// Doubling stops at MaxRetryDelay instead of overflowing.
+	retry, err = suite.finalizeRetry(boone.Retry{Count: 100, Backoff: "40m"})
+	require.NoError(t, err)
+	require.Exactly(t, 40*time.Minute, retry.Delay(1))
+	require.Exactly(t, boone.MaxRetryDelay, retry.Delay(2))
+	require.Exactly(t, boone.MaxRetryDelay, retry.Delay(100))
+
+	retry, err = suite.finalizeRetry(boone.Retry{})
+	require.NoError(t, err)
+	require.Exactly(t, 1, retry.Attempts())
+}
+
+func (suite *RetrySuite) TestInvalid() {
+	t := suite.T()
+
+	_, err := suite.finalizeRetry(boone.Retry{Count: -1})
+	require.Error(t, err)
+
+	_, err = suite.finalizeRetry(boone.Retry{Count: 1, Backoff: "soon"})
+	require.Error(t, err)
+
+	_, err = suite.finalizeRetry(boone.Retry{Count: 1, Backoff: "-1s"})
+	require.Error(t, err)
+
+	_, err = suite.finalizeRetry(boone.Retry{Count: 1, Backoff: "2h"})
+	require.Error(t, err)
+
+	_, err = suite.finalizeRetry(boone.Retry{Count: 1, Output: "("})
+	require.Error(t, err)
+}
+
+func (suite *RetrySuite) TestBackoffUsesClock() {
+	t := suite.T()
+
+	_, root := testkit_file.CreateDir(t, "target")
+	target := shTarget(root, "some target", "[ -f failed ] || { touch failed; exit 1; }")
+	target.Handler[0].Exec[0].Retry = boone.Retry{Count: 1, Backoff: "1h"}
+
+	// The mock timer has already expired, so the retry does not wait for the hour-long backoff.
+	timer, clock, timerCh, timerChReadonly := testkit_time.NewDebounceTimer(nil)
+	timer.On("C").Return(timerChReadonly)
+	timerCh <- time.Now()
+
+	run := runTree(t, &boone.Dispatcher{Clock: clock}, target)
+	require.Empty(t, run.fails)
+	require.Len(t, run.passes, 1)
+	clock.AssertCalled(t, "NewTimer", time.Hour)
+}
+
+func (suite *RetrySuite) TestAttemptText() {
+	t := suite.T()
+
+	require.Exactly(t, "", boone.AttemptText(1, 1))
+	require.Exactly(t, "", boone.AttemptText(0, 0))
+	require.Exactly(t, "attempt 2/3", boone.AttemptText(2, 3))
+}
+
+func TestRetrySuite(t *testing.T) {
+	suite.Run(t, new(RetrySuite))
+}
@@ -0,0 +1,178 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package boone
+
+import (
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+
+	cage_gob "github.com/codeactual/boone/internal/cage/encoding/gob"
+	cage_file "github.com/codeactual/boone/internal/cage/os/file"
+)
+
+// SessionSource describes where a Session value was read from.
+type SessionSource string
+
+const (
+	// SessionFromControl indicates the Session was received from a running instance's control socket.
+	SessionFromControl SessionSource = "running instance"
+
+	// SessionFromFile indicates the Session was decoded from Data.Session.File.
+	SessionFromFile SessionSource = "session file"
+)
+
+const (
+	// SessionFileHeartbeat is how often a running instance updates the modification time of
+	// Data.Session.File, even if no status changed, so readers can tell the file is current.
+	SessionFileHeartbeat = 10 * time.Second
+
+	// SessionFileStaleAfter is how long Data.Session.File may go without an update before readers
+	// consider it left behind by an instance which is no longer running.
+	SessionFileStaleAfter = 3 * SessionFileHeartbeat
+)
+
+// StatusSummary groups the statuses of a Session by whether they need attention or are still in progress.
+type StatusSummary struct {
+	// Failing holds statuses whose latest command failed or was canceled, or which were skipped
+	// because an upstream target failed.
+	//
+	// Each status is followed by its Status.Failures so that every failed handler is included.
+	Failing []Status
+
+	// Pending holds statuses which are debouncing, enqueued, or scheduled to resume.
+	Pending []Status
+
+	// Running holds statuses whose commands are currently executing.
+	Running []Status
+
+	// Warned holds statuses whose Handler.AllowFailure handlers failed, which do not need attention.
+	Warned []Status
+
+	// Flaky holds TargetPassed statuses of targets whose last run only passed after Exec.Retry ran a
+	// command again, which do not need attention.
+	Flaky []Status
+}
+
+// NewStatusSummary groups the input statuses by TargetStatus.
+//
+// Session.Flaky statuses may be included to populate Flaky.
+func NewStatusSummary(statuses []Status) (s StatusSummary) {
+	for _, status := range statuses {
+		switch status.Cause {
+		case TargetFailed, TargetCanceled, TargetTimedOut, TargetSkipped:
+			s.Failing = append(s.Failing, status)
+			s.Failing = append(s.Failing, status.Failures...)
+		case TargetDebouncing, TargetPending, TargetResumed:
+			s.Pending = append(s.Pending, status)
+		case TargetStarted:
+			s.Running = append(s.Running, status)
+		case TargetWarned:
+			s.Warned = append(s.Warned, status)
+		case TargetPassed:
+			if len(status.Flaky) > 0 {
+				s.Flaky = append(s.Flaky, status)
+			}
+		}
+	}
+	return s
+}
+
+// Idle returns true if no target is pending or running.
+func (s StatusSummary) Idle() bool {
+	return len(s.Pending) == 0 && len(s.Running) == 0
+}
+
+// Summary returns the StatusSummary of Statuses and Flaky.
+func (s Session) Summary() StatusSummary {
+	return NewStatusSummary(append(append([]Status{}, s.Statuses...), s.Flaky...))
+}
+
+// ReadSessionFile decodes a Session from a file written by the root command.
+//
+// An empty or missing file produces an empty Session.
+func ReadSessionFile(name string) (s Session, err error) {
+	exists, fi, err := cage_file.Exists(name)
+	if err != nil {
+		return Session{}, errors.Wrapf(err, "failed to inspect session file [%s]", name)
+	}
+	if !exists || fi.Size() == 0 {
+		return Session{}, nil
+	}
+
+	dec, err := cage_gob.DecodeFromFile(name)
+	if err != nil {
+		return Session{}, errors.Wrapf(err, "failed to create session file [%s] decoder", name)
+	}
+	if err = dec.Decode(&s); err != nil {
+		return Session{}, errors.Wrapf(err, "failed to decode session file [%s]", name)
+	}
+
+	return s, nil
+}
+
+// ReadSession returns the newest available Session: from a running instance if Data.Control.File
+// is configured and accepting connections, otherwise from Data.Session.File.
+func ReadSession(c Config) (s Session, src SessionSource, err error) {
+	if c.Data.Control.File != "" {
+		res, reqErr := SendControlRequest(c.Data.Control.File, ControlRequest{Op: ControlOpSession})
+		if reqErr == nil {
+			return res.Session, SessionFromControl, nil
+		}
+		if c.Data.Session.File == "" {
+			return Session{}, "", errors.WithStack(reqErr)
+		}
+	}
+
+	if c.Data.Session.File == "" {
+		return Session{}, "", errors.New("config defines neither [Data.Control.File] nor [Data.Session.File]")
+	}
+
+	s, err = ReadSessionFile(c.Data.Session.File)
+	if err != nil {
+		return Session{}, "", errors.WithStack(err)
+	}
+
+	return s, SessionFromFile, nil
+}
+
+// TouchSessionFile updates the modification time of the session file to signal that the instance
+// which writes it is still running.
+func TouchSessionFile(name string) error {
+	now := time.Now()
+	if err := os.Chtimes(name, now, now); err != nil {
+		return errors.Wrapf(err, "failed to update session file [%s] modification time", name)
+	}
+	return nil
+}
+
+// CheckSessionFile returns an error if the Session read from the file has debouncing, pending, or
+// running statuses but the file has not been updated for SessionFileStaleAfter, i.e. those statuses
+// were left behind by an instance which is no longer running and will never change.
+func CheckSessionFile(name string, s Session) error {
+	if NewStatusSummary(s.Statuses).Idle() {
+		return nil
+	}
+
+	exists, fi, err := cage_file.Exists(name)
+	if err != nil {
+		return errors.Wrapf(err, "failed to inspect session file [%s]", name)
+	}
+	if !exists {
+		return nil
+	}
+
+	if age := time.Since(fi.ModTime()); age > SessionFileStaleAfter {
+		return errors.Errorf(
+			"session file [%s] lists targets in progress but was last updated %s ago, so no running instance is updating it",
+			name, age.Round(time.Second),
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package boone_test
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/codeactual/boone/internal/boone"
+	cage_gob "github.com/codeactual/boone/internal/cage/encoding/gob"
+	"github.com/codeactual/boone/internal/cage/testkit"
+	testkit_file "github.com/codeactual/boone/internal/cage/testkit/os/file"
+)
+
+type SessionSuite struct {
+	suite.Suite
+
+	cfg boone.Config
+
+	session boone.Session
+}
+
+func (suite *SessionSuite) SetupTest() {
+	t := suite.T()
+
+	testkit_file.ResetTestdata(t)
+	dataDir := testkit_file.DynamicDataDirAbs(t)
+
+	suite.cfg = boone.Config{
+		Data: boone.DataConfig{
+			Control: boone.ControlConfig{File: filepath.Join(dataDir, "boone.sock")},
+			Session: boone.SessionConfig{File: filepath.Join(dataDir, "session")},
+		},
+	}
+
+	suite.session = boone.Session{
+		Version: boone.SessionVersion,
+		Statuses: []boone.Status{
+			{TargetId: "t0", TargetLabel: "t0 label", Cause: boone.TargetFailed},
+			{TargetId: "t1", TargetLabel: "t1 label", Cause: boone.TargetPending},
+			{TargetId: "t2", TargetLabel: "t2 label", Cause: boone.TargetStarted},
+			{TargetId: "t3", TargetLabel: "t3 label", Cause: boone.TargetCanceled},
+			{TargetId: "t4", TargetLabel: "t4 label", Cause: boone.TargetResumed},
+			{TargetId: "t5", TargetLabel: "t5 label", Cause: boone.TargetDebouncing},
+			{TargetId: "t6", TargetLabel: "t6 label", Cause: boone.TargetWarned},
+			{TargetId: "t7", TargetLabel: "t7 label", Cause: boone.TargetSkipped},
+		},
+	}
+}
+
+func (suite *SessionSuite) TestStatusSummary() {
+	t := suite.T()
+
+	summary := boone.NewStatusSummary(suite.session.Statuses)
+
+	require.Exactly(t, []boone.Status{suite.session.Statuses[0], suite.session.Statuses[3], suite.session.Statuses[7]}, summary.Failing)
+	require.Exactly(t, []boone.Status{suite.session.Statuses[1], suite.session.Statuses[4], suite.session.Statuses[5]}, summary.Pending)
+	require.Exactly(t, []boone.Status{suite.session.Statuses[2]}, summary.Running)
+	require.Exactly(t, []boone.Status{suite.session.Statuses[6]}, summary.Warned)
+	require.False(t, summary.Idle())
+	require.True(t, boone.NewStatusSummary(nil).Idle())
+	require.True(t, boone.NewStatusSummary(suite.session.Statuses[6:]).Idle())
+	require.Empty(t, summary.Flaky)
+
+	// Session.Flaky statuses are only summarized as flaky.
+	session := suite.session
+	session.Flaky = []boone.Status{{TargetId: "t8", TargetLabel: "t8 label", Cause: boone.TargetPassed, Flaky: []string{"test passed on attempt 2/3"}}}
+	summary = session.Summary()
+	require.Exactly(t, session.Flaky, summary.Flaky)
+	require.Len(t, summary.Failing, 3)
+	require.Len(t, summary.Pending, 3)
+	require.True(t, boone.Session{Flaky: session.Flaky}.Summary().Idle())
+}
+
+func (suite *SessionSuite) TestRerunnable() {
+	t := suite.T()
+
+	var rerunnable []string
+	for _, status := range suite.session.Statuses {
+		if status.Rerunnable() {
+			rerunnable = append(rerunnable, status.TargetId)
+		}
+	}
+	require.Exactly(t, []string{"t0", "t3", "t6", "t7"}, rerunnable)
+}
+
+func (suite *SessionSuite) TestReadSessionFromFile() {
+	t := suite.T()
+
+	require.NoError(t, cage_gob.EncodeToFile(suite.cfg.Data.Session.File, suite.session))
+
+	// Control.File is configured but no instance is listening.
+	actual, src, err := boone.ReadSession(suite.cfg)
+	require.NoError(t, err)
+	require.Exactly(t, boone.SessionFromFile, src)
+	require.Exactly(t, suite.session.Statuses, actual.Statuses)
+}
+
+func (suite *SessionSuite) TestReadSessionFlakyFromFile() {
+	t := suite.T()
+
+	suite.session.Flaky = []boone.Status{{TargetId: "t8", TargetLabel: "t8 label", Cause: boone.TargetPassed, Flaky: []string{"test passed on attempt 2/3"}}}
+	require.NoError(t, cage_gob.EncodeToFile(suite.cfg.Data.Session.File, suite.session))
+
+	actual, _, err := boone.ReadSession(suite.cfg)
+	require.NoError(t, err)
+	require.Exactly(t, suite.session.Flaky, actual.Flaky)
+}
+
+func (suite *SessionSuite) TestCheckSessionFile() {
+	t := suite.T()
+
+	name := suite.cfg.Data.Session.File
+	idle := boone.Session{Statuses: suite.session.Statuses[6:]}
+
+	require.NoError(t, cage_gob.EncodeToFile(name, suite.session))
+	require.NoError(t, boone.CheckSessionFile(name, suite.session))
+
+	// In-progress statuses in a file which a running instance stopped updating will never change.
+	old := time.Now().Add(-2 * boone.SessionFileStaleAfter)
+	require.NoError(t, os.Chtimes(name, old, old))
+	require.Error(t, boone.CheckSessionFile(name, suite.session))
+	require.NoError(t, boone.CheckSessionFile(name, idle))
+
+	require.NoError(t, boone.TouchSessionFile(name))
+	require.NoError(t, boone.CheckSessionFile(name, suite.session))
+
+	require.NoError(t, boone.CheckSessionFile(filepath.Join(filepath.Dir(name), "missing"), suite.session))
+}
+
+func (suite *SessionSuite) TestReadSessionFromMissingFile() {
+	t := suite.T()
+
+	actual, src, err := boone.ReadSession(suite.cfg)
+	require.NoError(t, err)
+	require.Exactly(t, boone.SessionFromFile, src)
+	require.Empty(t, actual.Statuses)
+}
+
+func (suite *SessionSuite) TestReadSessionFromControl() {
+	t := suite.T()
+
+	server, err := boone.NewControlServer(testkit.NewZapLogger(), suite.cfg.Data.Control.File)
+	require.NoError(t, err)
+	go server.Start()
+	defer server.Stop()
+
+	server.SetSession(suite.session)
+
+	actual, src, err := boone.ReadSession(suite.cfg)
+	require.NoError(t, err)
+	require.Exactly(t, boone.SessionFromControl, src)
+	require.Exactly(t, suite.session.Statuses, actual.Statuses)
+
+	// Only one instance can own the socket.
+	_, err = boone.NewControlServer(testkit.NewZapLogger(), suite.cfg.Data.Control.File)
+	require.Error(t, err)
+}
+
+func (suite *SessionSuite) TestControlIdleConnection() {
+	t := suite.T()
+
+	server, err := boone.NewControlServer(testkit.NewZapLogger(), suite.cfg.Data.Control.File)
+	require.NoError(t, err)
+	go server.Start()
+	defer server.Stop()
+
+	// The server closes a connection which never sends a request.
+	conn, err := net.Dial("unix", suite.cfg.Data.Control.File)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(boone.ControlConnTimeout+5*time.Second)))
+	_, err = conn.Read(make([]byte, 1))
+	require.Error(t, err)
+	netErr, ok := err.(net.Error)
+	require.False(t, ok && netErr.Timeout(), "server did not close the connection")
+
+	// Other clients are still served.
+	_, src, err := boone.ReadSession(suite.cfg)
+	require.NoError(t, err)
+	require.Exactly(t, boone.SessionFromControl, src)
+}
+
+func (suite *SessionSuite) TestReadSessionWithoutConfig() {
+	t := suite.T()
+
+	_, _, err := boone.ReadSession(boone.Config{})
+	require.Error(t, err)
+}
+
+func TestSessionSuite(t *testing.T) {
+	suite.Run(t, new(SessionSuite))
+}
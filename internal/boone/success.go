@@ -0,0 +1,91 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package boone
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Evaluate returns nil if the finished command passed according to SuccessCodes, FailOnOutput, and PassOnOutput,
+// otherwise an error which explains the failed criterion.
+//
+// The input error and exit code are from the command's execution, where the code is from the first failed
+// command in the pipeline or 0 if all succeeded. Criteria are evaluated in this order:
+//
+//   - FailOnOutput matches standard output or error: fail
+//   - PassOnOutput matches standard output or error: pass
+//   - SuccessCodes is configured: pass if it contains the exit code
+//   - otherwise: pass if the input error is nil
+//
+// It must not be used if the command was canceled or timed out, because the output may be incomplete.
+func (e Exec) Evaluate(runErr error, code int, stdout, stderr string) error {
+	if e.failOnOutput != nil {
+		if line, ok := firstMatch(e.failOnOutput, stdout, stderr); ok {
+			return errors.Errorf("output matched FailOnOutput [%s]: %s", e.FailOnOutput, line)
+		}
+	}
+
+	if e.passOnOutput != nil {
+		if _, ok := firstMatch(e.passOnOutput, stdout, stderr); ok {
+			return nil
+		}
+	}
+
+	if len(e.SuccessCodes) > 0 {
+		for _, c := range e.SuccessCodes {
+			// Errors which are not from a non-zero exit, e.g. a missing executable, are never a success.
+			if c == code && (runErr == nil || code != 0) {
+				return nil
+			}
+		}
+		if runErr == nil {
+			return errors.Errorf("exit code %d is not one of SuccessCodes %v", code, e.SuccessCodes)
+		}
+		return errors.Wrapf(runErr, "exit code %d is not one of SuccessCodes %v", code, e.SuccessCodes)
+	}
+
+	return runErr
+}
+
+// firstMatch returns the trimmed line which contains the first regular expression match in either output.
+func firstMatch(re *regexp.Regexp, outputs ...string) (line string, ok bool) {
+	for _, output := range outputs {
+		loc := re.FindStringIndex(output)
+		if loc == nil {
+			continue
+		}
+		start := strings.LastIndex(output[:loc[0]], "\n") + 1
+		end := len(output)
+		if n := strings.Index(output[loc[1]:], "\n"); n > -1 {
+			end = loc[1] + n
+		}
+		return strings.TrimSpace(output[start:end]), true
+	}
+	return "", false
+}
+
+// finalizeSuccess validates the SuccessCodes, FailOnOutput, and PassOnOutput fields and populates
+// the compiled versions.
+func finalizeSuccess(e *Exec) error {
+	var err error
+	if e.FailOnOutput != "" {
+		e.failOnOutput, err = regexp.Compile(e.FailOnOutput)
+		if err != nil {
+			return errors.Wrapf(err, "failed to compile FailOnOutput [%s]", e.FailOnOutput)
+		}
+	}
+	if e.PassOnOutput != "" {
+		e.passOnOutput, err = regexp.Compile(e.PassOnOutput)
+		if err != nil {
+			return errors.Wrapf(err, "failed to compile PassOnOutput [%s]", e.PassOnOutput)
+		}
+	}
+	return nil
+}
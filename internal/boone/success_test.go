@@ -0,0 +1,94 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package boone_test
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/codeactual/boone/internal/boone"
+	testkit_file "github.com/codeactual/boone/internal/cage/testkit/os/file"
+)
+
+type SuccessSuite struct {
+	suite.Suite
+}
+
+func (suite *SuccessSuite) SetupTest() {
+	testkit_file.ResetTestdata(suite.T())
+}
+
+func (suite *SuccessSuite) TestEvaluateDefault() {
+	t := suite.T()
+
+	exe, err := finalizeExec(boone.Exec{Cmd: "true"})
+	require.NoError(t, err)
+
+	exitErr := errors.New("exit status 1")
+	require.NoError(t, exe.Evaluate(nil, 0, "", ""))
+	require.Exactly(t, exitErr, exe.Evaluate(exitErr, 1, "", ""))
+}
+
+func (suite *SuccessSuite) TestEvaluateSuccessCodes() {
+	t := suite.T()
+
+	exe, err := finalizeExec(boone.Exec{Cmd: "true", SuccessCodes: []int{0, 1}})
+	require.NoError(t, err)
+
+	require.NoError(t, exe.Evaluate(nil, 0, "", ""))
+	require.NoError(t, exe.Evaluate(errors.New("exit status 1"), 1, "", ""))
+	require.EqualError(
+		t,
+		exe.Evaluate(errors.New("exit status 2"), 2, "", ""),
+		"exit code 2 is not one of SuccessCodes [0 1]: exit status 2",
+	)
+	require.Error(t, exe.Evaluate(errors.New("executable file not found"), 0, "", ""))
+
+	exe, err = finalizeExec(boone.Exec{Cmd: "true", SuccessCodes: []int{1}})
+	require.NoError(t, err)
+	require.EqualError(t, exe.Evaluate(nil, 0, "", ""), "exit code 0 is not one of SuccessCodes [1]")
+}
+
+func (suite *SuccessSuite) TestEvaluateOutput() {
+	t := suite.T()
+
+	exe, err := finalizeExec(boone.Exec{Cmd: "true", FailOnOutput: `(?m)^\s*\S+:\d+: `})
+	require.NoError(t, err)
+	require.NoError(t, exe.Evaluate(nil, 0, "all good\n", ""))
+	require.EqualError(
+		t,
+		exe.Evaluate(nil, 0, "checking\n", "2 findings\n  a.go:3: unused x  \nb.go:4: unused y\n"),
+		`output matched FailOnOutput [(?m)^\s*\S+:\d+: ]: a.go:3: unused x`,
+	)
+
+	exe, err = finalizeExec(boone.Exec{Cmd: "true", PassOnOutput: `(?m)^0 issues$`})
+	require.NoError(t, err)
+	require.NoError(t, exe.Evaluate(errors.New("exit status 3"), 3, "", "0 issues\n"))
+	require.Error(t, exe.Evaluate(errors.New("exit status 3"), 3, "", "1 issues\n"))
+
+	// FailOnOutput takes precedence.
+	exe, err = finalizeExec(boone.Exec{Cmd: "true", FailOnOutput: "FAIL", PassOnOutput: "PASS"})
+	require.NoError(t, err)
+	require.EqualError(t, exe.Evaluate(nil, 0, "PASS\nFAIL", ""), "output matched FailOnOutput [FAIL]: FAIL")
+}
+
+func (suite *SuccessSuite) TestInvalid() {
+	t := suite.T()
+
+	_, err := finalizeExec(boone.Exec{Cmd: "true", FailOnOutput: "("})
+	require.Error(t, err)
+
+	_, err = finalizeExec(boone.Exec{Cmd: "true", PassOnOutput: "["})
+	require.Error(t, err)
+}
+
+func TestSuccessSuite(t *testing.T) {
+	suite.Run(t, new(SuccessSuite))
+}
@@ -22,7 +22,20 @@ import (
 type TargetTree struct {
 	Id      string
 	Label   string
+	Root    string
 	Handler []Handler
+
+	// OnFailure is a copy of Target.OnFailure.
+	OnFailure string
+
+	// Timeout is a copy of Target.Timeout.
+	Timeout string
+
+	// Upstream is a copy of Target.Upstream.
+	Upstream []string
+
+	// timeout is a copy of the parsed Target.Timeout.
+	timeout time.Duration
 }
 
 // Target defines upstream-target and/or filesystem triggers, and the handlers
@@ -39,9 +52,22 @@ type Target struct {
 	// It is generated at startup.
 	Downstream []*Target
 
+	// Env holds "KEY=VALUE" pairs added to the environment of all handler commands.
+	//
+	// They take precedence over GlobalConfig.Env and are overwritten by Exec.Env.
+	Env []string
+
+	// EnvFile holds paths to .env-style files, relative to Root, whose "KEY=VALUE" pairs are added to
+	// the environment of all handler commands before Env. They are read before each run.
+	EnvFile []string
+
 	// Exclude defines the path patterns of files/directories which should invalidate an Include match.
 	Exclude []cage_filepath.Glob
 
+	// Go enables the CmdTemplateData.Packages and AffectedPackages variables, resolved from the
+	// import graph of the packages under Root.
+	Go bool
+
 	// Handler defines the commands to run if the target is triggered by write-activity.
 	Handler []Handler
 
@@ -59,12 +85,25 @@ type Target struct {
 	// It is a required field.
 	Label string
 
+	// OnFailure selects which of the remaining targets in a Tree still run after one of this target's
+	// commands fails: OnFailureStopTree, OnFailureSkipDependents, or OnFailureContinue.
+	//
+	// It defaults to GlobalConfig.OnFailure.
+	OnFailure string
+
 	// Root is the default path prefix value for Include.Root fields.
 	Root string
 
+	// Timeout is a time.Duration compatible string which limits how long all of the target's handlers
+	// may take in one run.
+	//
+	// If empty, only each command's Exec.Timeout applies.
+	Timeout string
+
 	// Tree holds one item per Target which Dispatcher should execute when this Target is
 	// triggered. It includes ths Target in the first item, followed by all downstream
-	// targets found recursively.
+	// targets found recursively. Each downstream target is listed once, after all of its
+	// upstream targets in the tree.
 	//
 	// It only holds the minimum details of each target in order to avoid data races,
 	// e.g. that might happen with a map of Target/*Target.
@@ -77,6 +116,9 @@ type Target struct {
 
 	// debounce is the parsed version of Debounce.
 	debounce time.Duration
+
+	// timeout is the parsed version of Timeout.
+	timeout time.Duration
 }
 
 // GetDebounce returns the parsed version of Debounce.
@@ -107,16 +149,18 @@ func (t *Target) MatchPath(name string) (cage_filepath.MatchAnyOutput, error) {
 
 // ExpandTemplateVars updates Target configuration string fields by expanding template variables
 // with associated input values.
+//
+// Handler.Exec.Cmd is excluded because it is expanded before each run by Exec.ExpandCmd.
 func (t *Target) ExpandTemplateVars(data map[string]string) error {
 	targetStrings := []*string{
 		&t.Debounce,
 		&t.Root,
+		&t.Timeout,
 	}
 	for h, handler := range t.Handler {
 		for e := range handler.Exec {
 			targetStrings = append(
 				targetStrings,
-				&t.Handler[h].Exec[e].Cmd,
 				&t.Handler[h].Exec[e].Dir,
 				&t.Handler[h].Exec[e].Timeout,
 			)
@@ -136,6 +180,51 @@ func (t *Target) ContainsDownstream(targetId string) (found bool) {
 	return found
 }
 
+// orderTree returns the tree with each target listed once and after all of its upstream targets which are
+// also in the tree, e.g. so that a run of the diamond A -> (B, C) -> D reaches D only after both B and C
+// have finished. Otherwise the order of the input, from VisitDownstream, is kept.
+func orderTree(tree []TargetTree) []TargetTree {
+	inTree := map[string]bool{}
+	var remaining []TargetTree
+	for _, t := range tree {
+		if !inTree[t.Id] {
+			inTree[t.Id] = true
+			remaining = append(remaining, t)
+		}
+	}
+
+	// The first target triggered the run, so its own upstream targets are not waited on.
+	ordered := []TargetTree{remaining[0]}
+	listed := map[string]bool{remaining[0].Id: true}
+	remaining = remaining[1:]
+
+	for len(remaining) > 0 {
+		next := -1
+		for n, t := range remaining {
+			ready := true
+			for _, id := range t.Upstream {
+				if inTree[id] && !listed[id] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				next = n
+				break
+			}
+		}
+		if next == -1 { // only possible with an Upstream cycle, on which VisitDownstream would not have returned
+			return append(ordered, remaining...)
+		}
+
+		ordered = append(ordered, remaining[next])
+		listed[remaining[next].Id] = true
+		remaining = append(remaining[:next], remaining[next+1:]...)
+	}
+
+	return ordered
+}
+
 // VisitDownstream calls the visitor with all targets found downstream recursively.
 func VisitDownstream(t *Target, visit func(t *Target) error) (err error) {
 	for _, d := range t.Downstream {
@@ -8,6 +8,8 @@ package boone_test
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
 	"path/filepath"
 	"testing"
 	"time"
@@ -66,12 +68,38 @@ func (suite *TargetSuite) requireHandlerExactly(expected, actual boone.Handler,
 	t := suite.T()
 	handlerCaseId := fmt.Sprintf("%s handler [%s]", baseCaseId, expected.Label)
 	require.Exactly(t, expected.Label, actual.Label, handlerCaseId)
+	require.Exactly(t, expected.AllowFailure, actual.AllowFailure, handlerCaseId)
+	require.Exactly(t, expected.If.Include, actual.If.Include, handlerCaseId)
+	require.Exactly(t, expected.If.Exclude, actual.If.Exclude, handlerCaseId)
+	require.Exactly(t, expected.If.Op, actual.If.Op, handlerCaseId)
+	require.Exactly(t, expected.If.When, actual.If.When, handlerCaseId)
+	require.Exactly(t, expected.Parallel, actual.Parallel, handlerCaseId)
+	require.Exactly(t, expected.Needs, actual.Needs, handlerCaseId)
 	require.Exactly(t, len(expected.Exec), len(actual.Exec), handlerCaseId)
 	for e, actualExec := range actual.Exec {
 		execCaseId := fmt.Sprintf("%s exec %d", handlerCaseId, e)
 		require.Exactly(t, expected.Exec[e].Cmd, actualExec.Cmd, execCaseId)
 		require.Exactly(t, expected.Exec[e].Dir, actualExec.Dir, execCaseId)
 		require.Exactly(t, expected.Exec[e].Timeout, actualExec.Timeout, execCaseId)
+		require.Exactly(t, expected.Exec[e].Color, actualExec.Color, execCaseId)
+		require.Exactly(t, expected.Exec[e].ColorEnv(), actualExec.ColorEnv(), execCaseId)
+		require.Exactly(t, expected.Exec[e].Format, actualExec.Format, execCaseId)
+		require.Exactly(t, expected.Exec[e].Shell, actualExec.Shell, execCaseId)
+		require.Exactly(t, expected.Exec[e].Env, actualExec.Env, execCaseId)
+		require.Exactly(t, expected.Exec[e].SuccessCodes, actualExec.SuccessCodes, execCaseId)
+		require.Exactly(t, expected.Exec[e].FailOnOutput, actualExec.FailOnOutput, execCaseId)
+		require.Exactly(t, expected.Exec[e].PassOnOutput, actualExec.PassOnOutput, execCaseId)
+		require.Exactly(t, expected.Exec[e].Retry.Count, actualExec.Retry.Count, execCaseId)
+		require.Exactly(t, expected.Exec[e].Retry.Backoff, actualExec.Retry.Backoff, execCaseId)
+		require.Exactly(t, expected.Exec[e].Retry.Code, actualExec.Retry.Code, execCaseId)
+		require.Exactly(t, expected.Exec[e].Retry.Output, actualExec.Retry.Output, execCaseId)
+		require.Exactly(t, expected.Exec[e].EnvFile, actualExec.EnvFile, execCaseId)
+		require.Exactly(t, len(expected.Exec[e].ProblemMatcher), len(actualExec.ProblemMatcher), execCaseId)
+		for m, actualMatcher := range actualExec.ProblemMatcher {
+			require.Exactly(t, expected.Exec[e].ProblemMatcher[m].Preset, actualMatcher.Preset, execCaseId)
+			require.Exactly(t, expected.Exec[e].ProblemMatcher[m].Pattern, actualMatcher.Pattern, execCaseId)
+			require.Exactly(t, expected.Exec[e].ProblemMatcher[m].Severity, actualMatcher.Severity, execCaseId)
+		}
 		expectedTimeoutDuration, err := time.ParseDuration(expected.Exec[e].Timeout)
 		require.NoError(t, err)
 		require.Exactly(t, expectedTimeoutDuration, actualExec.GetTimeout(), execCaseId)
@@ -85,6 +113,11 @@ func (suite *TargetSuite) requireTargetExactly(expected, actual boone.Target) {
 	require.Exactly(t, expected.Label, actual.Label, targetCaseId)
 	require.Exactly(t, expected.Root, actual.Root, targetCaseId)
 	require.Exactly(t, expected.Id, actual.Id, targetCaseId)
+	require.Exactly(t, expected.Go, actual.Go, targetCaseId)
+	require.Exactly(t, expected.OnFailure, actual.OnFailure, targetCaseId)
+	require.Exactly(t, expected.Timeout, actual.Timeout, targetCaseId)
+	require.Exactly(t, expected.Env, actual.Env, targetCaseId)
+	require.Exactly(t, expected.EnvFile, actual.EnvFile, targetCaseId)
 
 	require.Exactly(t, expected.Debounce, actual.Debounce, targetCaseId)
 	expectedDebounceDuration, err := time.ParseDuration(expected.Debounce)
@@ -106,6 +139,10 @@ func (suite *TargetSuite) requireTargetExactly(expected, actual boone.Target) {
 
 		require.Exactly(t, expected.Tree[s].Id, actualTarget.Id, treeTargetCaseId)
 		require.Exactly(t, expected.Tree[s].Label, actualTarget.Label, treeTargetCaseId)
+		require.Exactly(t, expected.Tree[s].Root, actualTarget.Root, treeTargetCaseId)
+		require.Exactly(t, expected.Tree[s].OnFailure, actualTarget.OnFailure, treeTargetCaseId)
+		require.Exactly(t, expected.Tree[s].Timeout, actualTarget.Timeout, treeTargetCaseId)
+		require.Exactly(t, expected.Tree[s].Upstream, actualTarget.Upstream, treeTargetCaseId)
 
 		require.Exactly(t, len(expected.Tree[s].Handler), len(actualTarget.Handler), treeTargetCaseId)
 		for h, actualHandler := range actualTarget.Handler {
@@ -120,7 +157,8 @@ func (suite *TargetSuite) TestReadConfigFile() {
 	require.Exactly(
 		t,
 		boone.DataConfig{
-			Session: boone.SessionConfig{File: "testdata/dynamic/path/to/boone/session"},
+			Quickfix: boone.QuickfixConfig{File: "testdata/dynamic/path/to/boone/quickfix", Format: boone.DefaultQuickfixFormat},
+			Session:  boone.SessionConfig{File: "testdata/dynamic/path/to/boone/session"},
 		},
 		suite.cfg.Data,
 	)
@@ -135,7 +173,12 @@ func (suite *TargetSuite) TestReadConfigFile() {
 	)
 
 	expectedGlobal := boone.GlobalConfig{
-		Cooldown: "10s",
+		Cooldown:    "10s",
+		Editor:      "code -g {{.File}}:{{.Line}}:{{.Column}}",
+		Env:         []string{"GLOBAL_ENV=global"},
+		OnFailure:   boone.OnFailureSkipDependents,
+		TreeTimeout: "30m",
+		EnvFile:     []string{filepath.Join(thisDir, "testdata", "dynamic", "global.env")},
 		Exclude: []cage_filepath.Glob{
 			{Pattern: "global/exclude/0/glob"},
 			{Pattern: "global/exclude/1/glob"},
@@ -151,6 +194,36 @@ func (suite *TargetSuite) TestReadConfigFile() {
 		10*time.Second,
 		suite.cfg.Global.GetCooldown(),
 	)
+	require.Exactly(
+		t,
+		expectedGlobal.Editor,
+		suite.cfg.Global.Editor,
+	)
+	require.Exactly(
+		t,
+		expectedGlobal.Env,
+		suite.cfg.Global.Env,
+	)
+	require.Exactly(
+		t,
+		expectedGlobal.OnFailure,
+		suite.cfg.Global.OnFailure,
+	)
+	require.Exactly(
+		t,
+		expectedGlobal.TreeTimeout,
+		suite.cfg.Global.TreeTimeout,
+	)
+	require.Exactly(
+		t,
+		30*time.Minute,
+		suite.cfg.Global.GetTreeTimeout(),
+	)
+	require.Exactly(
+		t,
+		expectedGlobal.EnvFile,
+		suite.cfg.Global.EnvFile,
+	)
 	require.Exactly(
 		t,
 		expectedGlobal.Exclude,
@@ -159,10 +232,14 @@ func (suite *TargetSuite) TestReadConfigFile() {
 
 	expectedTarget := []boone.Target{
 		{
-			Label:    "target 0 label",
-			Root:     suite.target0Root,
-			Id:       "target 0 id",
-			Debounce: "10s",
+			Label:     "target 0 label",
+			Root:      suite.target0Root,
+			Id:        "target 0 id",
+			Debounce:  "10s",
+			OnFailure: boone.OnFailureSkipDependents,
+			Timeout:   "20m",
+			Env:       []string{"TARGET_ENV=target"},
+			EnvFile:   []string{suite.target0Root + "/target.env"},
 			Include: []cage_filepath.Glob{
 				{
 					Pattern: suite.target0Root + "/include/0/glob",
@@ -193,7 +270,8 @@ func (suite *TargetSuite) TestReadConfigFile() {
 			},
 			Handler: []boone.Handler{
 				{
-					Label: "target 0 handler 0 label",
+					Label:    "target 0 handler 0 label",
+					Parallel: true,
 					Exec: []boone.Exec{{
 						Cmd:     "target 0 handler 0 cmd",
 						Dir:     suite.target0Root,
@@ -201,7 +279,9 @@ func (suite *TargetSuite) TestReadConfigFile() {
 					}},
 				},
 				{
-					Label: "target 0 handler 1 label",
+					Label:        "target 0 handler 1 label",
+					AllowFailure: true,
+					Needs:        []string{"target 0 handler 0 label"},
 					Exec: []boone.Exec{{
 						Cmd:     "target 0 handler 1 cmd",
 						Dir:     suite.target0Root,
@@ -211,10 +291,11 @@ func (suite *TargetSuite) TestReadConfigFile() {
 			},
 		},
 		{
-			Label:    "target 1 label",
-			Root:     suite.target1Root,
-			Debounce: "5s",
-			Id:       "auto-generated Id: [target 1 label][" + suite.target1Root + "]",
+			Label:     "target 1 label",
+			Root:      suite.target1Root,
+			Debounce:  "5s",
+			OnFailure: boone.OnFailureSkipDependents,
+			Id:        "auto-generated Id: [target 1 label][" + suite.target1Root + "]",
 			Include: []cage_filepath.Glob{
 				{
 					Pattern: suite.target1Root + "/include/0/root/include/0/glob",
@@ -239,21 +320,32 @@ func (suite *TargetSuite) TestReadConfigFile() {
 			Handler: []boone.Handler{
 				{
 					Label: "target 1 handler 0 label",
+					If: boone.HandlerIf{
+						Include: []cage_filepath.Glob{{Pattern: "**/*.proto"}},
+						Exclude: []cage_filepath.Glob{{Pattern: "vendor/**"}},
+						Op:      []string{"Create", "Write"},
+						When:    "test -f buf.yaml",
+					},
 					Exec: []boone.Exec{{
-						Cmd:     "target 1 handler 0 cmd",
-						Dir:     suite.target1Root,
-						Timeout: "6m",
+						Cmd:          "target 1 handler 0 cmd {{.debounce_profile}} ./{{dir .RelPath}} {{.TargetId | quote}}",
+						Dir:          suite.target1Root,
+						Timeout:      "6m",
+						SuccessCodes: []int{0, 1},
+						FailOnOutput: `^\S+:\d+: `,
+						PassOnOutput: "no changes",
 					}},
 				},
 			},
 			Downstream: []*boone.Target{},
 		},
 		{
-			Label:    "target 2 label",
-			Root:     suite.target2Root,
-			Id:       "target 2 id",
-			Debounce: "15s",
-			Upstream: []string{"target 0 id"},
+			Label:     "target 2 label",
+			Root:      suite.target2Root,
+			Id:        "target 2 id",
+			Go:        true,
+			Debounce:  "15s",
+			OnFailure: boone.OnFailureContinue,
+			Upstream:  []string{"target 0 id"},
 			Handler: []boone.Handler{
 				{
 					Label: "target 2 handler 0 label",
@@ -261,6 +353,13 @@ func (suite *TargetSuite) TestReadConfigFile() {
 						Cmd:     "target 2 handler 0 cmd",
 						Dir:     suite.target2Root,
 						Timeout: "15m",
+						Shell:   "sh -c",
+						Retry: boone.Retry{
+							Count:   2,
+							Backoff: "3s",
+							Code:    []int{1, 2},
+							Output:  "connection refused",
+						},
 					}},
 				},
 			},
@@ -277,11 +376,12 @@ func (suite *TargetSuite) TestReadConfigFile() {
 			Downstream: []*boone.Target{},
 		},
 		{
-			Label:    "target 3 label",
-			Root:     suite.target3Root,
-			Debounce: "15s",
-			Id:       "target 3 id",
-			Upstream: []string{"target 2 id"},
+			Label:     "target 3 label",
+			Root:      suite.target3Root,
+			Debounce:  "15s",
+			OnFailure: boone.OnFailureSkipDependents,
+			Id:        "target 3 id",
+			Upstream:  []string{"target 2 id"},
 			Handler: []boone.Handler{
 				{
 					Label: "target 3 handler 0 label",
@@ -290,11 +390,20 @@ func (suite *TargetSuite) TestReadConfigFile() {
 							Cmd:     "target 3 handler 0 cmd",
 							Dir:     suite.target3Root,
 							Timeout: "15m",
+							Color:   boone.ExecColorForce,
+							ProblemMatcher: []boone.ProblemMatcher{
+								{Preset: boone.ProblemPresetGoTest, Pattern: boone.ProblemPresets[boone.ProblemPresetGoTest][0], Severity: boone.DefaultProblemSeverity},
+								{Preset: boone.ProblemPresetGoTest, Pattern: boone.ProblemPresets[boone.ProblemPresetGoTest][1], Severity: boone.DefaultProblemSeverity},
+								{Pattern: `^(?P<file>\S+) line (?P<line>\d+): (?P<message>.+)$`, Severity: "warning"},
+							},
 						},
 						{
 							Cmd:     "target 3 handler 1 cmd",
 							Dir:     suite.target3ExecDir,
 							Timeout: "15m",
+							Format:  boone.ExecFormatGoTestJSON,
+							Env:     []string{"EXEC_ENV=exec"},
+							EnvFile: []string{suite.target3ExecDir + "/exec.env"},
 						},
 					},
 				},
@@ -318,58 +427,86 @@ func (suite *TargetSuite) TestReadConfigFile() {
 
 	expectedTarget[0].Tree = []boone.TargetTree{
 		{
-			Id:      expectedTarget[0].Id,
-			Label:   expectedTarget[0].Label,
-			Handler: expectedTarget[0].Handler,
+			Id:        expectedTarget[0].Id,
+			Label:     expectedTarget[0].Label,
+			Root:      expectedTarget[0].Root,
+			Handler:   expectedTarget[0].Handler,
+			OnFailure: expectedTarget[0].OnFailure,
+			Timeout:   expectedTarget[0].Timeout,
+			Upstream:  expectedTarget[0].Upstream,
 		},
 	}
 	for _, d := range expectedTarget[0].Downstream {
 		expectedTarget[0].Tree = append(
 			expectedTarget[0].Tree,
 			boone.TargetTree{
-				Id:      d.Id,
-				Label:   d.Label,
-				Handler: d.Handler,
+				Id:        d.Id,
+				Label:     d.Label,
+				Root:      d.Root,
+				Handler:   d.Handler,
+				OnFailure: d.OnFailure,
+				Timeout:   d.Timeout,
+				Upstream:  d.Upstream,
 			},
 		)
 	}
 	expectedTarget[0].Tree = append(
 		expectedTarget[0].Tree,
 		boone.TargetTree{ // transitive: downstream of downstream
-			Id:      expectedTarget[3].Id,
-			Label:   expectedTarget[3].Label,
-			Handler: expectedTarget[3].Handler,
+			Id:        expectedTarget[3].Id,
+			Label:     expectedTarget[3].Label,
+			Root:      expectedTarget[3].Root,
+			Handler:   expectedTarget[3].Handler,
+			OnFailure: expectedTarget[3].OnFailure,
+			Timeout:   expectedTarget[3].Timeout,
+			Upstream:  expectedTarget[3].Upstream,
 		},
 	)
 	expectedTarget[1].Tree = []boone.TargetTree{
 		{
-			Id:      expectedTarget[1].Id,
-			Label:   expectedTarget[1].Label,
-			Handler: expectedTarget[1].Handler,
+			Id:        expectedTarget[1].Id,
+			Label:     expectedTarget[1].Label,
+			Root:      expectedTarget[1].Root,
+			Handler:   expectedTarget[1].Handler,
+			OnFailure: expectedTarget[1].OnFailure,
+			Timeout:   expectedTarget[1].Timeout,
+			Upstream:  expectedTarget[1].Upstream,
 		},
 	}
 	expectedTarget[2].Tree = []boone.TargetTree{
 		{
-			Id:      expectedTarget[2].Id,
-			Label:   expectedTarget[2].Label,
-			Handler: expectedTarget[2].Handler,
+			Id:        expectedTarget[2].Id,
+			Label:     expectedTarget[2].Label,
+			Root:      expectedTarget[2].Root,
+			Handler:   expectedTarget[2].Handler,
+			OnFailure: expectedTarget[2].OnFailure,
+			Timeout:   expectedTarget[2].Timeout,
+			Upstream:  expectedTarget[2].Upstream,
 		},
 	}
 	for _, d := range expectedTarget[2].Downstream {
 		expectedTarget[2].Tree = append(
 			expectedTarget[2].Tree,
 			boone.TargetTree{
-				Id:      d.Id,
-				Label:   d.Label,
-				Handler: d.Handler,
+				Id:        d.Id,
+				Label:     d.Label,
+				Root:      d.Root,
+				Handler:   d.Handler,
+				OnFailure: d.OnFailure,
+				Timeout:   d.Timeout,
+				Upstream:  d.Upstream,
 			},
 		)
 	}
 	expectedTarget[3].Tree = []boone.TargetTree{
 		{
-			Id:      expectedTarget[3].Id,
-			Label:   expectedTarget[3].Label,
-			Handler: expectedTarget[3].Handler,
+			Id:        expectedTarget[3].Id,
+			Label:     expectedTarget[3].Label,
+			Root:      expectedTarget[3].Root,
+			Handler:   expectedTarget[3].Handler,
+			OnFailure: expectedTarget[3].OnFailure,
+			Timeout:   expectedTarget[3].Timeout,
+			Upstream:  expectedTarget[3].Upstream,
 		},
 	}
 
@@ -383,6 +520,61 @@ func (suite *TargetSuite) TestReadConfigFile() {
 	suite.requireTargetExactly(expectedTarget[2], startTarget[0])
 }
 
+func (suite *TargetSuite) TestReadConfigFileFromOtherDir() {
+	t := suite.T()
+
+	_, configDir := testkit_file.CreateDir(t, "config")
+	configFile := filepath.Join(configDir, "boone.yaml")
+	require.NoError(t, ioutil.WriteFile(configFile, []byte("Global:\n  EnvFile:\n    - global.env\n    - /abs/global.env\n"), 0600))
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	_, otherDir := testkit_file.CreateDir(t, "other", "wd")
+	require.NoError(t, os.Chdir(otherDir))
+	defer func() {
+		require.NoError(t, os.Chdir(wd))
+	}()
+
+	// Global.EnvFile paths are relative to the config file, not the working directory.
+	cfg, err := boone.ReadConfigFile(configFile)
+	require.NoError(t, err)
+	require.Exactly(t, []string{filepath.Join(configDir, "global.env"), "/abs/global.env"}, cfg.Global.EnvFile)
+}
+
+func (suite *TargetSuite) TestExpandCmd() {
+	t := suite.T()
+
+	exe := suite.cfg.Target[1].Handler[0].Exec[0]
+
+	cmd, err := exe.ExpandCmd(boone.CmdTemplateData{RelPath: "api/handler.go", TargetId: "it's"}, nil)
+	require.NoError(t, err)
+	require.Exactly(t, `target 1 handler 0 cmd 10s ./api 'it'\''s'`, cmd)
+
+	exe.Cmd = "{{undefinedFunc .Path}}"
+	_, err = exe.ExpandCmd(boone.CmdTemplateData{}, nil)
+	require.Error(t, err)
+}
+
+func (suite *TargetSuite) TestOnFailureInvalid() {
+	t := suite.T()
+
+	newTarget := func(onFailure string) *boone.Target {
+		return &boone.Target{
+			Label:     "some target",
+			Root:      suite.target0Root,
+			OnFailure: onFailure,
+			Handler:   []boone.Handler{{Label: "some handler", Exec: []boone.Exec{{Cmd: "true"}}}},
+		}
+	}
+
+	target := newTarget("")
+	require.NoError(t, boone.FinalizeConfig([]*boone.Target{target}, &boone.Config{}))
+	require.Exactly(t, boone.DefaultOnFailure, target.OnFailure)
+
+	require.Error(t, boone.FinalizeConfig([]*boone.Target{newTarget("skip")}, &boone.Config{}))
+	require.Error(t, boone.FinalizeConfig([]*boone.Target{newTarget("")}, &boone.Config{Global: boone.GlobalConfig{OnFailure: "skip"}}))
+}
+
 func (suite *TargetSuite) TestContainsDownstream() {
 	t := suite.T()
 
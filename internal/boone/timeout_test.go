@@ -0,0 +1,156 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package boone_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/codeactual/boone/internal/boone"
+	testkit_file "github.com/codeactual/boone/internal/cage/testkit/os/file"
+)
+
+type TimeoutSuite struct {
+	suite.Suite
+
+	root string
+
+	// started holds the statuses sent via Dispatcher.TargetStartCh during the last run.
+	started []boone.Status
+}
+
+func (suite *TimeoutSuite) SetupTest() {
+	t := suite.T()
+	testkit_file.ResetTestdata(t)
+	_, suite.root = testkit_file.CreateDir(t, "target")
+}
+
+// newTarget returns a target rooted in suite.root. See shTarget.
+func (suite *TimeoutSuite) newTarget(label, script string, upstream ...string) *boone.Target {
+	return shTarget(suite.root, label, script, upstream...)
+}
+
+// run dispatches the tree of the first target and returns the statuses of failed targets after the
+// run finishes. Statuses of pending and started targets are collected in suite.started.
+func (suite *TimeoutSuite) run(treeTimeout, cooldown time.Duration, targets ...*boone.Target) []boone.Status {
+	run := runTree(suite.T(), &boone.Dispatcher{TreeTimeout: treeTimeout, Cooldown: cooldown}, targets...)
+	suite.started = run.started
+	return run.fails
+}
+
+func (suite *TimeoutSuite) TestTargetTimeout() {
+	t := suite.T()
+
+	build := suite.newTarget("build", "touch build")
+	test := suite.newTarget("test", "sleep 5; touch test", "build")
+	test.Timeout = "500ms"
+	test.OnFailure = boone.OnFailureContinue
+	report := suite.newTarget("report", "touch report", "test")
+
+	start := time.Now()
+	fails := suite.run(0, 0, build, test, report)
+	require.True(t, time.Since(start) < 5*time.Second)
+
+	require.Len(t, fails, 1)
+	require.Exactly(t, boone.TargetTimedOut, fails[0].Cause)
+	require.Exactly(t, "test", fails[0].TargetLabel)
+	require.Exactly(t, "Target.Timeout [500ms] reached at target 2/3 [test] (passed: build; remaining: report)", fails[0].Err)
+
+	requireFile(t, suite.root, "test", false)
+	requireFile(t, suite.root, "report", true) // Target.Timeout follows the OnFailure policy
+
+	summary := boone.NewStatusSummary(fails)
+	require.Len(t, summary.Failing, 1)
+}
+
+func (suite *TimeoutSuite) TestTreeTimeout() {
+	t := suite.T()
+
+	build := suite.newTarget("build", "touch build")
+	test := suite.newTarget("test", "sleep 5; touch test", "build")
+	test.OnFailure = boone.OnFailureContinue
+	report := suite.newTarget("report", "touch report", "test")
+
+	fails := suite.run(time.Second, 0, build, test, report)
+
+	require.Len(t, fails, 2)
+	require.Exactly(t, boone.TargetTimedOut, fails[0].Cause)
+	require.Exactly(t, "Global.TreeTimeout [1s] reached at target 2/3 [test] (passed: build; remaining: report)", fails[0].Err)
+	require.Exactly(t, boone.TargetSkipped, fails[1].Cause)
+	require.Exactly(t, "report", fails[1].TargetLabel)
+	require.Exactly(t, "skipped because the tree run stopped at target [test]", fails[1].Err)
+
+	requireFile(t, suite.root, "build", true)
+	requireFile(t, suite.root, "test", false)
+	requireFile(t, suite.root, "report", false) // Global.TreeTimeout ends the run regardless of OnFailure
+}
+
+func (suite *TimeoutSuite) TestTreeTimeoutBetweenTargets() {
+	t := suite.T()
+
+	build := suite.newTarget("build", "touch build")
+	test := suite.newTarget("test", "touch test", "build")
+	report := suite.newTarget("report", "touch report", "test")
+
+	// The deadline passes during the Cooldown after the first target's command.
+	fails := suite.run(500*time.Millisecond, time.Second, build, test, report)
+
+	require.Len(t, fails, 2)
+	require.Exactly(t, boone.TargetTimedOut, fails[0].Cause)
+	require.Exactly(t, "test", fails[0].HandlerLabel)
+	require.Exactly(t, "Global.TreeTimeout [500ms] reached at target 2/3 [test] (passed: build; remaining: report)", fails[0].Err)
+	require.Exactly(t, boone.TargetSkipped, fails[1].Cause)
+	require.Exactly(t, "report", fails[1].TargetLabel)
+
+	requireFile(t, suite.root, "build", true)
+	requireFile(t, suite.root, "test", false)
+	requireFile(t, suite.root, "report", false)
+}
+
+func (suite *TimeoutSuite) TestTreePending() {
+	t := suite.T()
+
+	build := suite.newTarget("build", "touch build")
+	test := suite.newTarget("test", "touch test", "build")
+	report := suite.newTarget("report", "touch report", "test")
+
+	// The Cooldown between targets leaves the tree without a running target.
+	fails := suite.run(0, 500*time.Millisecond, build, test, report)
+	require.Empty(t, fails)
+
+	// Downstream targets are pending from the start of the run, after the enqueued target's own status,
+	// until they start.
+	require.True(t, len(suite.started) > 3)
+	require.Exactly(t, "build", suite.started[0].TargetLabel)
+	require.Exactly(t, boone.TargetPending, suite.started[0].Cause)
+	require.Empty(t, suite.started[0].UpstreamTargetLabel)
+	for n, label := range []string{"test", "report"} {
+		require.Exactly(t, label, suite.started[n+1].TargetLabel)
+		require.Exactly(t, boone.TargetPending, suite.started[n+1].Cause)
+		require.Exactly(t, "build", suite.started[n+1].UpstreamTargetLabel)
+	}
+	require.Exactly(t, "build", suite.started[3].TargetLabel)
+	require.Exactly(t, boone.TargetStarted, suite.started[3].Cause)
+}
+
+func (suite *TimeoutSuite) TestInvalid() {
+	t := suite.T()
+
+	target := suite.newTarget("build", "true")
+	target.Timeout = "soon"
+	require.Error(t, boone.FinalizeConfig([]*boone.Target{target}, &boone.Config{}))
+
+	target = suite.newTarget("build", "true")
+	require.Error(t, boone.FinalizeConfig([]*boone.Target{target}, &boone.Config{Global: boone.GlobalConfig{TreeTimeout: "soon"}}))
+}
+
+func TestTimeoutSuite(t *testing.T) {
+	suite.Run(t, new(TimeoutSuite))
+}
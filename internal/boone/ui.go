@@ -8,6 +8,11 @@ package boone
 
 import (
 	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	tp_runes "github.com/codeactual/boone/internal/third_party/stackexchange/runes"
@@ -26,7 +31,7 @@ const (
 	BodyBoxTopPad = 1
 
 	// DetailListMaxLen is the static row length of the status-detail list.
-	DetailListMaxLen = 3
+	DetailListMaxLen = 5
 
 	// DetailStderrPos positions standard error as the first status-detail list item.
 	DetailStderrPos = 0
@@ -37,13 +42,52 @@ const (
 	// DetailMiscPos positions misc. details as the third status-detail list item.
 	DetailMiscPos = 2
 
+	// DetailProblemsPos positions diagnostics found by problem matchers as the fourth status-detail list item.
+	DetailProblemsPos = 3
+
+	// DetailTestsPos positions the failed tests of Exec.Format ExecFormatGoTestJSON commands as the fifth status-detail list item.
+	DetailTestsPos = 4
+
 	// ListItemWidgetPad is the all-sides padding of every ListItemWidget.
 	ListItemWidgetPad = 1
 
-	// StatusListMaxLen is the static row length of the status list.
-	StatusListMaxLen = 9
+	// StatusListItemMinHeight is the number of screen rows reserved for each visible status list item:
+	// its padding, header, and at least two lines of its body.
+	//
+	// The number of visible items is the screen height, minus the "N more" line, divided by this value.
+	StatusListItemMinHeight = 2*ListItemWidgetPad + 1 + BodyBoxTopPad + 2
+
+	// StatusListShortcutLen is the number of status list items selectable via digit keypress.
+	StatusListShortcutLen = 9
 )
 
+// errorLinePattern matches lines of command output which look like the start of a failure.
+var errorLinePattern = regexp.MustCompile(`(?m)^.*(\bFAIL\b|panic:|(?i:error):).*$`)
+
+// targetHistory describes a target's past runs for display in the target browser.
+type targetHistory struct {
+	// Result is the Cause of the last failure, or TargetPassed.
+	Result TargetStatus
+
+	// EndTime is when the last run finished.
+	EndTime time.Time
+
+	// PassCount is how many runs have passed.
+	PassCount int
+
+	// PassRunLen is the total duration of all passed runs.
+	PassRunLen time.Duration
+
+	// FlakyCount is how many passed runs included a command which only passed after Exec.Retry ran it again.
+	FlakyCount int
+
+	// Flaky is a copy of TargetPass.Flaky from the last run, if it passed.
+	Flaky []string
+
+	// SkippedHandler is a copy of TargetPass.SkippedHandler from the last run, if it passed.
+	SkippedHandler []string
+}
+
 // ListItemWidget is used to represent the status and status-detail lists.
 type ListItemWidget struct {
 	// Container is the flexible height/width box which bounds the Header and Body areas.
@@ -95,10 +139,22 @@ type UI struct {
 	// Its contents are updated when the UI receives an Status or TargetPass over a channel.
 	statusListWidget *tview.Flex
 
-	// statusListItemWidget represents one status/item in statusListWidget.
+	// statusListItemWidget represents one visible status/item in statusListWidget, starting at statusListOffset.
 	//
-	// Its contents are updated when the UI receives an Status or TargetPass over a channel.
-	statusListItemWidget [StatusListMaxLen]*ListItemWidget
+	// Its length is statusListPageLen, and its contents are updated when the UI receives an Status or TargetPass over a channel.
+	statusListItemWidget []*ListItemWidget
+
+	// statusListPageLen is how many status list items fit on the screen, updated when the screen is resized.
+	statusListPageLen int
+
+	// statusListMoreWidget indicates how many items are scrolled out of view above/below the visible ones.
+	statusListMoreWidget *tview.TextView
+
+	// statusListOffset is the status list position of the first visible item.
+	statusListOffset int
+
+	// statusListVisible is how many statusListItemWidget are currently added to statusListWidget.
+	statusListVisible int
 
 	// detailListWidget holds the stderr/stdout/misc widgets and is shown when a specific
 	// status/item in statusListWidget is selected via numbered keypress.
@@ -112,6 +168,81 @@ type UI struct {
 	// Its contents are updated at keypress time.
 	detailListItemWidget [DetailListMaxLen]*ListItemWidget
 
+	// detailText holds the unformatted content of each detailListItemWidget body, e.g. for searching.
+	detailText [DetailListMaxLen]string
+
+	// detailViewWidget holds one detailListItemWidget body and detailSearchWidget, and is shown when
+	// a specific item in detailListWidget is selected via numbered keypress.
+	detailViewWidget *tview.Flex
+
+	// detailViewPos is the detailListItemWidget position displayed in detailViewWidget.
+	detailViewPos int
+
+	// detailSearchWidget receives search queries and displays the search position.
+	detailSearchWidget *tview.InputField
+
+	// detailSearchRe matches the hits of the current search, or is nil if there is no search.
+	detailSearchRe *regexp.Regexp
+
+	// detailSearchLabel describes the current search, e.g. "Search" or "Errors".
+	detailSearchLabel string
+
+	// detailSearchHit is the position of the highlighted hit.
+	detailSearchHit int
+
+	// detailSearchHits holds the start/end offsets in detailText of each hit of the current search.
+	detailSearchHits [][]int
+
+	// detailDir is the Status.Dir of the item shown in the detail list, used to resolve relative locations.
+	detailDir string
+
+	// WatchedPathCount optionally provides the watched path count of a target for display in the target browser.
+	WatchedPathCount func(targetId string) int
+
+	// Editor is the command template which opens locations selected in the detail view.
+	//
+	// If empty, DefaultEditor is used.
+	Editor string
+
+	// browserWidget holds the filter input and table of all configured targets, and is shown when
+	// selected from the status list via keypress.
+	browserWidget *tview.Flex
+
+	// browserFilter limits browserTable rows to targets whose label or Id contains its text.
+	browserFilter *tview.InputField
+
+	// browserTable holds one row per configured target.
+	//
+	// Its contents are updated whenever the status list is rendered and when the filter changes.
+	browserTable *tview.Table
+
+	// browserRows holds the Target.Id of each browserTable row after the header.
+	browserRows []string
+
+	// targets holds all configured targets in order to populate the target browser.
+	targets []Target
+
+	// targetHistory stores past run details indexed by Target.Id.
+	targetHistory map[string]*targetHistory
+
+	// historyMu guards targetHistory and its values, which maintainStatusList writes while drawBrowser
+	// reads them from the tview goroutine.
+	historyMu sync.Mutex
+
+	// execReqCh lets the UI rerun, cancel, and mute targets through the same channel used by Watcher.
+	execReqCh chan ExecRequest
+
+	// targetTree holds a copy of Target.Tree indexed by Target.Id in order to rerun targets.
+	targetTree map[string][]TargetTree
+
+	// selectedId is the Target.Id of the status list item which receives rerun/cancel/mute keypresses.
+	//
+	// If the target is no longer in the list, the first item is selected.
+	selectedId string
+
+	// muted holds the Id of every target muted via keypress.
+	muted map[string]bool
+
 	// exitCh lets UI communicate if Ctrl-C was captured.
 	exitCh chan struct{}
 
@@ -132,6 +263,10 @@ type UI struct {
 	// It supports both the list and detail views.
 	statusList []Status
 
+	// statusListMu guards statusList and selectedId. maintainStatusList is the only writer of statusList
+	// and reads it without the lock, while the tview goroutine reads copies from statuses/selection.
+	statusListMu sync.RWMutex
+
 	// activeWidget replaces use of tview.Box.HasFocus which is not predictable to know which
 	// one is in the foreground (and there is no tview.Application.GetRoot or similar).
 	activeWidget tview.Primitive
@@ -151,9 +286,20 @@ func (u *UI) SessionCh() <-chan Session {
 }
 
 // NewUI returns a UI instance configured to listen for status updates from the input channel.
-func NewUI(log *zap.Logger, targetStartCh chan Status, targetPassCh chan TargetPass, targetFailCh chan Status, statusList []Status) *UI {
+//
+// Rerun/cancel/mute requests made via keypress are sent to execReqCh.
+func NewUI(log *zap.Logger, targets []Target, execReqCh chan ExecRequest, targetStartCh chan Status, targetPassCh chan TargetPass, targetFailCh chan Status, statusList []Status) *UI {
+	targetTree := make(map[string][]TargetTree)
+	for _, t := range targets {
+		targetTree[t.Id] = append([]TargetTree{}, t.Tree...)
+	}
+
 	return &UI{
 		log:           log,
+		targets:       targets,
+		execReqCh:     execReqCh,
+		targetTree:    targetTree,
+		muted:         make(map[string]bool),
 		targetStartCh: targetStartCh,
 		targetPassCh:  targetPassCh,
 		targetFailCh:  targetFailCh,
@@ -167,11 +313,13 @@ func NewUI(log *zap.Logger, targetStartCh chan Status, targetPassCh chan TargetP
 func (u *UI) Init() {
 	u.statusListWidget = tview.NewFlex()
 	u.statusListWidget.SetDirection(tview.FlexRow)
-	for pos := 0; pos < StatusListMaxLen; pos++ {
-		u.statusListItemWidget[pos] = NewListItemWidget()
-		u.statusListWidget.AddItem(u.statusListItemWidget[pos].Container, 0, 1, false)
-	}
+	u.statusListMoreWidget = tview.NewTextView()
+	u.statusListMoreWidget.SetDynamicColors(true)
+	u.statusListMoreWidget.SetBorderPadding(0, 0, ListItemWidgetPad, ListItemWidgetPad)
+	u.statusListWidget.AddItem(u.statusListMoreWidget, 1, 0, false) // fixed height of 1
 	u.statusListWidget.SetFullScreen(true)
+	u.statusListPageLen = 1
+	u.statusListItemWidget = []*ListItemWidget{NewListItemWidget()}
 
 	u.detailListWidget = tview.NewFlex()
 	u.detailListWidget.SetDirection(tview.FlexRow)
@@ -181,12 +329,66 @@ func (u *UI) Init() {
 	u.detailListWidget.AddItem(u.detailListItemWidget[DetailStderrPos].Container, 0, 1, false)
 	u.detailListWidget.AddItem(u.detailListItemWidget[DetailStdoutPos].Container, 0, 1, false)
 	u.detailListWidget.AddItem(u.detailListItemWidget[DetailMiscPos].Container, 0, 1, false)
+	u.detailListWidget.AddItem(u.detailListItemWidget[DetailProblemsPos].Container, 0, 1, false)
+	u.detailListWidget.AddItem(u.detailListItemWidget[DetailTestsPos].Container, 0, 1, false)
 	u.detailListWidget.SetFullScreen(true)
+	for pos := 0; pos < DetailListMaxLen; pos++ {
+		u.detailListItemWidget[pos].Body.SetRegions(true) // for search hits
+	}
+
+	u.detailSearchWidget = tview.NewInputField()
+	u.detailSearchWidget.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter {
+			query := u.detailSearchWidget.GetText()
+			if query == "" {
+				u.setDetailSearch(nil, "")
+			} else {
+				u.setDetailSearch(regexp.MustCompile("(?i)"+regexp.QuoteMeta(query)), "Search")
+			}
+		}
+		u.app.SetFocus(u.detailListItemWidget[u.detailViewPos].Body)
+	})
+
+	u.detailViewWidget = tview.NewFlex()
+	u.detailViewWidget.SetDirection(tview.FlexRow)
+	u.detailViewWidget.SetFullScreen(true)
+
+	u.browserFilter = tview.NewInputField()
+	u.browserFilter.SetLabel("Filter: ")
+	u.browserFilter.SetChangedFunc(func(string) { u.drawBrowser() })
+	u.browserFilter.SetDoneFunc(func(tcell.Key) { u.app.SetFocus(u.browserTable) })
+
+	u.browserTable = tview.NewTable()
+	u.browserTable.SetSelectable(true, false)
+	u.browserTable.SetFixed(1, 0)
+	u.browserTable.SetSelectedFunc(func(row, _ int) {
+		if id, ok := u.browserTarget(row); ok {
+			for _, t := range u.targets {
+				if t.Id == id {
+					u.sendExecRequest(ExecRequest{
+						Cause:       ExecCauseBrowser,
+						TargetId:    t.Id,
+						TargetLabel: t.Label,
+						Tree:        append([]TargetTree{}, t.Tree...),
+					})
+				}
+			}
+		}
+	})
+
+	u.browserWidget = tview.NewFlex()
+	u.browserWidget.SetDirection(tview.FlexRow)
+	u.browserWidget.SetBorderPadding(ListItemWidgetPad, ListItemWidgetPad, ListItemWidgetPad, ListItemWidgetPad)
+	u.browserWidget.AddItem(u.browserFilter, 1, 0, false)
+	u.browserWidget.AddItem(u.browserTable, 0, 1, true)
+	u.browserWidget.SetFullScreen(true)
 
 	u.app = tview.NewApplication().SetInputCapture(u.InputCapture)
+	u.app.SetBeforeDrawFunc(u.resizeStatusList)
 	u.focusWidget(u.statusListWidget)
 
 	u.runLenHistory = make(map[string]time.Duration)
+	u.targetHistory = make(map[string]*targetHistory)
 }
 
 // Start begins the goroutines which update the UI based on new data from a Dispatcher, periodically
@@ -223,6 +425,13 @@ func (u *UI) Start() error {
 	return nil
 }
 
+// SetScreen replaces the terminal which Start draws to, e.g. with a tcell.SimulationScreen.
+//
+// It must be called after Init and before Start.
+func (u *UI) SetScreen(screen tcell.Screen) {
+	u.app.SetScreen(screen)
+}
+
 // Stop ends UI rendering and keyboard event capturing.
 //
 // It must be called to prevent corrupting the terminal such that `reset` is required. See tview's Fini
@@ -236,6 +445,7 @@ func (u *UI) Stop() {
 // maintainStatusList add, replace, and remove statuses from the list data.
 //
 // It does not directly update the UI widgets which render the list data (see renderStatusList).
+// It sends a Session message after each change (see publishSession).
 //
 // It should run in its own goroutine because its for-select blocks.
 func (u *UI) maintainStatusList() {
@@ -249,8 +459,15 @@ func (u *UI) maintainStatusList() {
 			}
 		}
 
+		u.statusListMu.Lock()
 		if targetPos == -1 {
 			u.statusList = append([]Status{status}, u.statusList...) // prepend new item
+		} else {
+			u.statusList[targetPos] = status // replace item, enforce policy of one item per target
+		}
+		u.statusListMu.Unlock()
+
+		if targetPos == -1 {
 
 			u.log.Info(
 				"add target",
@@ -259,8 +476,6 @@ func (u *UI) maintainStatusList() {
 				zap.String("cause", string(status.Cause)),
 			)
 		} else {
-			u.statusList[targetPos] = status // replace item, enforce policy of one item per target
-
 			u.log.Info(
 				"replace target (file activity)",
 				cage_zap.Tag("ui"),
@@ -269,8 +484,11 @@ func (u *UI) maintainStatusList() {
 			)
 		}
 		u.renderStatusList()
+		u.publishSession()
 	}
 
+	u.publishSession()
+
 	for {
 		select {
 		case status := <-u.targetStartCh:
@@ -278,6 +496,35 @@ func (u *UI) maintainStatusList() {
 		case pass := <-u.targetPassCh:
 			u.runLenHistory[pass.TargetId] = pass.RunLen
 
+			u.updateHistory(pass.TargetId, func(history *targetHistory) {
+				history.Result = TargetPassed
+				if len(pass.Warned) > 0 {
+					history.Result = TargetWarned
+				}
+				history.EndTime = time.Now()
+				history.PassCount++
+				history.PassRunLen += pass.RunLen
+				history.Flaky = pass.Flaky
+				history.SkippedHandler = pass.SkippedHandler
+				if len(pass.Flaky) > 0 {
+					history.FlakyCount++
+				}
+			})
+
+			// Keep the target listed, with its first warning, unless it's already pending another run.
+			if len(pass.Warned) > 0 {
+				var pending bool
+				for _, i := range u.statusList {
+					if i.TargetId == pass.TargetId && i.awaitingActivity() {
+						pending = true
+					}
+				}
+				if !pending {
+					insertItem(pass.Warned[0])
+				}
+				continue
+			}
+
 			foundPos := -1
 			for pos, i := range u.statusList {
 				if i.TargetId == pass.TargetId {
@@ -291,7 +538,9 @@ func (u *UI) maintainStatusList() {
 				for _, i := range u.statusList {
 					before = append(before, i.TargetLabel+"/"+i.HandlerLabel)
 				}
+				u.statusListMu.Lock()
 				u.statusList = append(u.statusList[:foundPos], u.statusList[foundPos+1:]...)
+				u.statusListMu.Unlock()
 				for _, i := range u.statusList {
 					after = append(after, i.TargetLabel+"/"+i.HandlerLabel)
 				}
@@ -303,14 +552,22 @@ func (u *UI) maintainStatusList() {
 					zap.Strings("before", before),
 					zap.Strings("after", after),
 				)
-				u.renderStatusList()
 			}
+			u.renderStatusList() // also update the target browser
+			u.publishSession()
 		case status := <-u.targetFailCh:
+			u.updateHistory(status.TargetId, func(history *targetHistory) {
+				history.Result = status.Cause
+				history.EndTime = status.EndTime
+				history.Flaky = nil
+				history.SkippedHandler = nil
+			})
+
 			// If the target received file activity while it was running and the list was already updated
-			// to reflect the pending state, retain that state to avoid it flipping from started to pending to failed.
+			// to reflect the debouncing/pending state, retain that state to avoid it flipping from started to pending to failed.
 			var pending bool
 			for _, i := range u.statusList {
-				if i.TargetId == status.TargetId && i.Cause == TargetPending {
+				if i.TargetId == status.TargetId && i.awaitingActivity() {
 					pending = true
 				}
 			}
@@ -322,130 +579,338 @@ func (u *UI) maintainStatusList() {
 	}
 }
 
-// renderStatusList complements maintainStatusList by rendering the current list data.
+// publishSession sends a Session message with the current list data, and the last run of each target
+// which only passed after a retry, in case the CLI is configured to write session files, aiming for those
+// files to be as up-to-date as possible.
 //
-// It also sends Session messages in case the CLI is configured to write session files,
-// aiming for those files to be as up-to-date as possible.
+// It never blocks: if the previous message has not been received yet, it is replaced because only the
+// newest one is worth saving. The send cannot block afterward because maintainStatusList is the only sender.
+func (u *UI) publishSession() {
+	select { // Discard the unreceived message, if any.
+	case <-u.sessionCh:
+	default:
+	}
+	session := Session{Statuses: append([]Status{}, u.statusList...)}
+	for _, t := range u.targets {
+		if h, ok := u.history(t.Id); ok && len(h.Flaky) > 0 { // Flaky is cleared if the last run failed
+			session.Flaky = append(session.Flaky, Status{
+				Cause:       TargetPassed,
+				EndTime:     h.EndTime,
+				Flaky:       h.Flaky,
+				TargetId:    t.Id,
+				TargetLabel: t.Label,
+			})
+		}
+	}
+	u.sessionCh <- session
+}
+
+// renderStatusList complements maintainStatusList by rendering the current list data.
 func (u *UI) renderStatusList() {
-	u.app.QueueUpdateDraw(func() {
-		listLen := len(u.statusList)
+	u.app.QueueUpdateDraw(u.drawStatusList)
+}
+
+// resizeStatusList updates how many status list items are visible, based on the screen height, before
+// each draw of the screen, e.g. after the terminal is resized.
+//
+// It implements the tview.Application.SetBeforeDrawFunc handler and never skips the draw.
+func (u *UI) resizeStatusList(screen tcell.Screen) bool {
+	_, height := screen.Size()
+
+	pageLen := (height - 1) / StatusListItemMinHeight // the "N more" line has a fixed height of 1
+	if pageLen < 1 {
+		pageLen = 1
+	}
+
+	if pageLen != u.statusListPageLen {
+		u.statusListPageLen = pageLen
+		for len(u.statusListItemWidget) < pageLen {
+			u.statusListItemWidget = append(u.statusListItemWidget, NewListItemWidget())
+		}
+		u.drawStatusList()
+	}
+
+	return false
+}
 
+// drawStatusList updates the status list widgets from the current list data.
+//
+// It must run in the tview goroutine, e.g. via QueueUpdateDraw.
+func (u *UI) drawStatusList() {
+	statusList, selectedPos := u.selection()
+	listLen := len(statusList)
+
+	u.log.Debug(
+		"drawStatusList",
+		cage_zap.Tag("ui"),
+		zap.Int("listLen", listLen),
+		zap.Int("pageLen", u.statusListPageLen),
+	)
+
+	pageLen := u.statusListPageLen
+
+	// Scroll just enough to keep the selected item visible.
+	if selectedPos < u.statusListOffset {
+		u.statusListOffset = selectedPos
+	} else if selectedPos >= u.statusListOffset+pageLen {
+		u.statusListOffset = selectedPos - pageLen + 1
+	}
+	if maxOffset := listLen - pageLen; u.statusListOffset > maxOffset {
+		u.statusListOffset = maxOffset
+	}
+	if u.statusListOffset < 0 {
+		u.statusListOffset = 0
+	}
+
+	above := u.statusListOffset
+	below := listLen - u.statusListOffset - pageLen
+	if below < 0 {
+		below = 0
+	}
+	var more []string
+	if above > 0 {
+		more = append(more, fmt.Sprintf("%d more above", above))
+	}
+	if below > 0 {
+		more = append(more, fmt.Sprintf("%d more below", below))
+	}
+	if len(more) > 0 {
+		u.statusListMoreWidget.SetText("[darkgray]" + strings.Join(more, " | ") + " (j/k to scroll)")
+	} else {
+		u.statusListMoreWidget.SetText("")
+	}
+
+	// Let visible items share all the vertical space instead of reserving it for empty slots.
+	// At least one slot is kept because tview.Flex requires a non-zero proportion total.
+	visible := listLen - u.statusListOffset
+	if visible > pageLen {
+		visible = pageLen
+	}
+	if visible < 1 {
+		visible = 1
+	}
+	if visible != u.statusListVisible {
+		for slot := range u.statusListItemWidget {
+			u.statusListWidget.RemoveItem(u.statusListItemWidget[slot].Container)
+		}
+		u.statusListWidget.RemoveItem(u.statusListMoreWidget)
+		for slot := 0; slot < visible; slot++ {
+			u.statusListWidget.AddItem(u.statusListItemWidget[slot].Container, 0, 1, false)
+		}
+		u.statusListWidget.AddItem(u.statusListMoreWidget, 1, 0, false) // fixed height of 1
+		u.statusListVisible = visible
+	}
+
+	for slot := 0; slot < pageLen; slot++ {
+		pos := u.statusListOffset + slot
+		w := u.statusListItemWidget[slot]
+
+		if pos >= listLen {
+			w.Container.SetBackgroundColor(tview.Styles.PrimitiveBackgroundColor)
+			w.Header.SetBackgroundColor(tview.Styles.PrimitiveBackgroundColor)
+			w.Body.SetBackgroundColor(tview.Styles.PrimitiveBackgroundColor)
+			w.Header.SetText("")
+			w.Body.SetText("")
+			continue
+		}
+
+		background := tview.Styles.PrimitiveBackgroundColor
+		if pos == selectedPos {
+			background = tcell.Color236
+		}
+		w.Container.SetBackgroundColor(background)
+		w.Header.SetBackgroundColor(background)
+		w.Body.SetBackgroundColor(background)
+
+		status := statusList[pos]
 		u.log.Debug(
-			"renderStatusList",
+			"render item",
 			cage_zap.Tag("ui"),
-			zap.Int("listLen", listLen),
+			zap.String("target", status.TargetLabel),
+			zap.String("handler", status.HandlerLabel),
 		)
 
-		for pos := 0; pos < StatusListMaxLen; pos++ {
-			if pos >= listLen {
-				u.statusListItemWidget[pos].Header.SetText("")
-				u.statusListItemWidget[pos].Body.SetText("")
-				continue
+		// decorate marks the selected item and muted targets.
+		decorate := func(header string) string {
+			if pos == selectedPos {
+				header = "[yellow]>[white] " + header
+			} else {
+				header = "  " + header
+			}
+			if u.muted[status.TargetId] {
+				header += "[white] | [yellow]muted"
 			}
+			return header
+		}
 
-			status := u.statusList[pos]
-			u.log.Debug(
-				"render item",
-				cage_zap.Tag("ui"),
-				zap.String("target", status.TargetLabel),
-				zap.String("handler", status.HandlerLabel),
-			)
+		if status.Cause == TargetStarted {
+			var priorRunLenStr string
+			priorRunLen, ok := u.runLenHistory[status.TargetId]
+			if ok {
+				priorRunLenStr = " | last took " + cage_time.DurationShort(priorRunLen)
+			} else {
+				priorRunLenStr = ""
+			}
 
-			if status.Cause == TargetStarted {
-				var priorRunLenStr string
-				priorRunLen, ok := u.runLenHistory[status.TargetId]
-				if ok {
-					priorRunLenStr = " | last took " + cage_time.DurationShort(priorRunLen)
-				} else {
-					priorRunLenStr = ""
-				}
+			var startTime string
+			age := time.Since(status.StartTime)
+			if age < time.Minute {
+				startTime = "now"
+			} else {
+				startTime = cage_time.DurationShort(age) + " ago"
+			}
 
-				var startTime string
-				age := time.Since(status.StartTime)
-				if age < time.Minute {
-					startTime = "now"
-				} else {
-					startTime = cage_time.DurationShort(age) + " ago"
-				}
+			var attemptStr string
+			if status.Attempt > 1 {
+				attemptStr = " (" + AttemptText(status.Attempt, status.Attempts) + ")"
+			}
 
-				t := fmt.Sprintf( // Only use darkgray so it draws the eye less
-					"[darkgray]%d) %s | %s | %s @ %s%s",
-					pos+1, status.TargetLabel, status.HandlerLabel, status.Cause, startTime, priorRunLenStr,
-				)
+			t := fmt.Sprintf( // Only use darkgray so it draws the eye less
+				"[darkgray]%d) %s | %s | %s%s @ %s%s",
+				pos+1, status.TargetLabel, status.HandlerLabel, status.Cause, attemptStr, startTime, priorRunLenStr,
+			)
 
-				u.statusListItemWidget[pos].Header.SetText(t)
-				u.statusListItemWidget[pos].Body.SetText("")
+			w.Header.SetText(decorate(t))
+			w.Body.SetText("")
 
-				continue
-			} else if status.Cause == TargetResumed {
-				// Support case where handler label is empty, e.g. status was in a pending state
-				// prior to shutdown and had not yet executed any handler.
-				var resumeHandler string
-				if status.HandlerLabel == "" {
-					resumeHandler = " |"
-				} else {
-					resumeHandler = " | " + status.HandlerLabel + " |"
-				}
+			continue
+		} else if status.Cause == TargetResumed {
+			// Support case where handler label is empty, e.g. status was in a pending state
+			// prior to shutdown and had not yet executed any handler.
+			var resumeHandler string
+			if status.HandlerLabel == "" {
+				resumeHandler = " |"
+			} else {
+				resumeHandler = " | " + status.HandlerLabel + " |"
+			}
 
-				t := fmt.Sprintf( // Only use darkgray so it draws the eye less
-					"[darkgray]%d) %s%s scheduled resume",
-					pos+1, status.TargetLabel, resumeHandler,
-				)
+			t := fmt.Sprintf( // Only use darkgray so it draws the eye less
+				"[darkgray]%d) %s%s scheduled resume",
+				pos+1, status.TargetLabel, resumeHandler,
+			)
 
-				u.statusListItemWidget[pos].Header.SetText(t)
-				u.statusListItemWidget[pos].Body.SetText("")
+			w.Header.SetText(decorate(t))
+			w.Body.SetText("")
 
-				continue
-			} else if status.Cause == TargetPending {
-				t := fmt.Sprintf( // Only use darkgray so it draws the eye less
-					"[darkgray]%d) %s | pending",
-					pos+1, status.TargetLabel,
-				)
+			continue
+		} else if status.Cause == TargetSkipped {
+			t := fmt.Sprintf( // Only use darkgray so it draws the eye less
+				"[darkgray]%d) %s | %s: %s",
+				pos+1, status.TargetLabel, status.Cause, status.Err,
+			)
 
-				u.statusListItemWidget[pos].Header.SetText(t)
-				u.statusListItemWidget[pos].Body.SetText("")
+			w.Header.SetText(decorate(t))
+			w.Body.SetText("")
 
-				continue
+			continue
+		} else if status.Cause == TargetPending || status.Cause == TargetDebouncing {
+			var upstreamStr string
+			if !status.awaitingActivity() {
+				upstreamStr = " after " + status.UpstreamTargetLabel
 			}
 
-			snip := status.Stderr
-			if snip == "" {
-				snip = status.Stdout
+			t := fmt.Sprintf( // Only use darkgray so it draws the eye less
+				"[darkgray]%d) %s | %s%s",
+				pos+1, status.TargetLabel, status.Cause, upstreamStr,
+			)
+
+			w.Header.SetText(decorate(t))
+			w.Body.SetText("")
+
+			continue
+		}
+
+		snip := status.Stderr
+		if snip == "" {
+			snip = status.Stdout
+		}
+		if snip == "" {
+			snip = "<empty stdout/stderr>"
+		}
+		if len(status.Diagnostics) > 0 {
+			snip = DiagnosticSummary(status.Diagnostics)
+			for _, d := range status.Diagnostics {
+				snip += "\n" + d.String()
 			}
-			if snip == "" {
-				snip = "<empty stdout/stderr>"
+		} else if FailedTestsPattern(status.Tests) != "" {
+			snip = TestSummary(status.Tests)
+			for _, r := range status.Tests {
+				if r.Action == TestFailed && r.Test != "" {
+					snip += "\nFAIL " + r.Name()
+				}
 			}
+		}
+		if status.Cause == TargetTimedOut { // explain why the output ends early
+			snip = status.Err + "\n" + snip
+		}
 
-			var endTime string
-			age := time.Since(status.EndTime)
-			if age < time.Minute {
-				endTime = "now"
-			} else {
-				endTime = cage_time.DurationShort(age) + " ago"
-			}
+		var endTime string
+		age := time.Since(status.EndTime)
+		if age < time.Minute {
+			endTime = "now"
+		} else {
+			endTime = cage_time.DurationShort(age) + " ago"
+		}
 
-			header := fmt.Sprintf(
-				"[darkgray]%d) [green]%s[white] | [darkgreen]%s[white] | [darkgray]%s after %s[lightgray] @ %s",
-				pos+1, status.TargetLabel, status.HandlerLabel, status.Cause, cage_time.DurationShort(status.RunLen), endTime,
-			)
+		var attemptStr string
+		if attemptText := AttemptText(status.Attempt, status.Attempts); attemptText != "" {
+			attemptStr = " on " + attemptText
+		}
 
-			u.statusListItemWidget[pos].Header.SetText(header)
-			u.statusListItemWidget[pos].Body.SetText(snip)
-			u.statusListItemWidget[pos].Body.ScrollToEnd()
+		causeColor := "darkgray"
+		if status.Cause == TargetWarned {
+			causeColor = "yellow"
 		}
-	})
 
-	u.sessionCh <- Session{Statuses: u.statusList}
+		var moreStr string
+		if n := len(status.Failures); n > 0 {
+			moreStr = fmt.Sprintf(" (+%d more)", n)
+		}
+
+		header := fmt.Sprintf(
+			"[darkgray]%d) [green]%s[white] | [darkgreen]%s[white] | [%s]%s%s after %s[lightgray] @ %s%s",
+			pos+1, status.TargetLabel, status.HandlerLabel, causeColor, status.Cause, attemptStr, cage_time.DurationShort(status.RunLen), endTime, moreStr,
+		)
+
+		w.Header.SetText(decorate(header))
+		w.Body.SetText(ansiText(snip))
+		if len(status.Diagnostics) > 0 || FailedTestsPattern(status.Tests) != "" {
+			w.Body.ScrollToBeginning() // keep the summary visible
+		} else {
+			w.Body.ScrollToEnd()
+		}
+	}
+
+	u.drawBrowser()
 }
 
 // InputCapture listens for keyboard events from all screens.
 func (u *UI) InputCapture(event *tcell.EventKey) *tcell.EventKey {
+	if (u.browserFilter.HasFocus() || u.detailSearchWidget.HasFocus()) && event.Key() != tcell.KeyCtrlC {
+		return event // let the input receive all text, including 'q'
+	}
+
 	if event.Key() == tcell.KeyCtrlC || event.Rune() == 'q' { // Allow exit from anywhere
 		u.exitCh <- struct{}{}
 		return &tcell.EventKey{} // prevent tview from internally calling Stop on the app
 	}
 
 	switch u.activeWidget {
+	case u.browserWidget:
+		switch {
+		// Use KeyBackSpace2 because KeyBackspace is actually Ctrl-H (https://github.com/gdamore/tcell/statuses/127)
+		case event.Key() == tcell.KeyBackspace2:
+			u.focusWidget(u.statusListWidget)
+		case event.Rune() == '/':
+			u.app.SetFocus(u.browserFilter)
+			return nil
+		case event.Rune() == 'm':
+			row, _ := u.browserTable.GetSelection()
+			if id, ok := u.browserTarget(row); ok {
+				u.toggleMute(id)
+			}
+		}
+		return event
 	case u.detailListWidget:
 		// Use KeyBackSpace2 because KeyBackspace is actually Ctrl-H (https://github.com/gdamore/tcell/statuses/127)
 		if event.Key() == tcell.KeyBackspace2 {
@@ -456,18 +921,90 @@ func (u *UI) InputCapture(event *tcell.EventKey) *tcell.EventKey {
 		pos, err := tp_runes.ToInt(event.Rune())
 
 		if err == nil && pos > 0 && pos-1 < DetailListMaxLen {
-			u.detailListItemWidget[pos-1].Body.ScrollToEnd()
-			u.focusWidget(u.detailListItemWidget[pos-1].Body)
+			u.openDetailView(pos - 1)
 		}
 
+		return event
+	case u.detailViewWidget:
+		switch {
+		// Use KeyBackSpace2 because KeyBackspace is actually Ctrl-H (https://github.com/gdamore/tcell/statuses/127)
+		case event.Key() == tcell.KeyBackspace2:
+			u.setDetailSearch(nil, "")
+			u.focusWidget(u.detailListWidget)
+		case event.Key() == tcell.KeyEscape:
+			u.setDetailSearch(nil, "")
+		case event.Rune() == '/':
+			u.detailSearchWidget.SetLabel("/")
+			u.detailSearchWidget.SetText("")
+			u.app.SetFocus(u.detailSearchWidget)
+			return nil
+		case event.Rune() == 'e':
+			if u.detailSearchRe == errorLinePattern {
+				u.moveDetailSearch(1)
+			} else {
+				u.setDetailSearch(errorLinePattern, "Errors")
+			}
+		case event.Rune() == 'l':
+			if u.detailSearchRe == locationPattern {
+				u.moveDetailSearch(1)
+			} else {
+				u.setDetailSearch(locationPattern, "Locations")
+			}
+		case event.Rune() == 'o':
+			u.openLocation()
+		case event.Rune() == 'n':
+			u.moveDetailSearch(1)
+		case event.Rune() == 'N':
+			u.moveDetailSearch(-1)
+		}
 		return event
 	case u.statusListWidget:
+		switch {
+		case event.Key() == tcell.KeyUp || event.Rune() == 'k':
+			u.selectPos(u.selectedPos() - 1)
+			return event
+		case event.Key() == tcell.KeyDown || event.Rune() == 'j':
+			u.selectPos(u.selectedPos() + 1)
+			return event
+		case event.Rune() == 'r':
+			if status, ok := u.selectedStatus(); ok && status.Rerunnable() {
+				u.sendExecRequest(u.rerunRequest(status))
+			}
+			return event
+		case event.Rune() == 'R':
+			var reqs []ExecRequest
+			for _, status := range u.statuses() {
+				if status.Rerunnable() {
+					reqs = append(reqs, u.rerunRequest(status))
+				}
+			}
+			u.sendExecRequest(reqs...)
+			return event
+		case event.Rune() == 'c':
+			if status, ok := u.selectedStatus(); ok && status.Cause == TargetStarted {
+				u.sendExecRequest(ExecRequest{Action: ExecCancel, Cause: "ui", TargetId: status.TargetId, TargetLabel: status.TargetLabel})
+			}
+			return event
+		case event.Rune() == 'm':
+			if status, ok := u.selectedStatus(); ok {
+				u.toggleMute(status.TargetId)
+			}
+			return event
+		case event.Rune() == 't':
+			u.drawBrowser()
+			u.focusWidget(u.browserWidget)
+			return event
+		}
+
 		pos, err := tp_runes.ToInt(event.Rune())
+		statusList := u.statuses()
+
+		if err == nil && pos > 0 && pos <= StatusListShortcutLen && pos-1 < len(statusList) {
+			u.selectPos(pos - 1)
 
-		if err == nil && pos > 0 && pos-1 < len(u.statusList) {
-			status := u.statusList[pos-1]
+			status := statusList[pos-1]
 
-			if status.Cause == TargetStarted || status.Cause == TargetPending {
+			if status.Cause == TargetStarted || status.Cause == TargetPending || status.Cause == TargetDebouncing {
 				return event
 			}
 
@@ -479,18 +1016,22 @@ func (u *UI) InputCapture(event *tcell.EventKey) *tcell.EventKey {
 				}
 			}
 
+			u.detailDir = status.Dir
+
 			u.detailListItemWidget[DetailStderrPos].Header.SetText(fmt.Sprintf(
 				"[darkgray]1) [green]stderr[lightgray] (length: %d)",
 				len(status.Stderr),
 			))
-			u.detailListItemWidget[DetailStderrPos].Body.SetText(status.Stderr)
+			u.detailText[DetailStderrPos] = status.Stderr
+			u.detailListItemWidget[DetailStderrPos].Body.SetText(ansiText(status.Stderr))
 			u.detailListItemWidget[DetailStderrPos].Body.ScrollToEnd()
 
 			u.detailListItemWidget[DetailStdoutPos].Header.SetText(fmt.Sprintf(
 				"[darkgray]2) [green]stdout[lightgray] (length: %d)",
 				len(status.Stdout),
 			))
-			u.detailListItemWidget[DetailStdoutPos].Body.SetText(status.Stdout)
+			u.detailText[DetailStdoutPos] = status.Stdout
+			u.detailListItemWidget[DetailStdoutPos].Body.SetText(ansiText(status.Stdout))
 			u.detailListItemWidget[DetailStdoutPos].Body.ScrollToEnd()
 
 			var upstream string
@@ -501,7 +1042,7 @@ func (u *UI) InputCapture(event *tcell.EventKey) *tcell.EventKey {
 			}
 
 			u.detailListItemWidget[DetailMiscPos].Header.SetText("[darkgray]3) [green]more[lightgray]")
-			u.detailListItemWidget[DetailMiscPos].Body.SetText(fmt.Sprintf(
+			u.detailText[DetailMiscPos] = fmt.Sprintf(
 				"- Error: %s\n"+
 					"- Activity: %s (%s)\n"+
 					"- Include: %s\n"+
@@ -512,23 +1053,451 @@ func (u *UI) InputCapture(event *tcell.EventKey) *tcell.EventKey {
 				status.Include.Pattern,
 				upstream,
 				downstream,
-			))
+			)
+			if attemptText := AttemptText(status.Attempt, status.Attempts); attemptText != "" {
+				u.detailText[DetailMiscPos] += "\n- Retry: " + string(status.Cause) + " on " + attemptText
+			}
+			for _, skipped := range status.SkippedHandler {
+				u.detailText[DetailMiscPos] += "\n- Skipped handler: " + skipped
+			}
+			for _, other := range status.Failures {
+				u.detailText[DetailMiscPos] += fmt.Sprintf("\n- Also %s: %s | %s: %s", other.Cause, other.HandlerLabel, other.Cmd, other.Err)
+			}
+			for _, other := range status.Failures {
+				u.detailText[DetailMiscPos] += fmt.Sprintf(
+					"\n\n%s | %s stderr:\n%s\n%s | %s stdout:\n%s",
+					other.HandlerLabel, other.Cause, other.Stderr,
+					other.HandlerLabel, other.Cause, other.Stdout,
+				)
+			}
+			u.detailListItemWidget[DetailMiscPos].Body.SetText(ansiText(u.detailText[DetailMiscPos]))
 			u.detailListItemWidget[DetailMiscPos].Body.ScrollToBeginning()
 
+			problems := "<none>"
+			if len(status.Diagnostics) > 0 {
+				var lines []string
+				for _, d := range status.Diagnostics {
+					lines = append(lines, d.String())
+				}
+				problems = strings.Join(lines, "\n")
+			}
+			u.detailListItemWidget[DetailProblemsPos].Header.SetText(fmt.Sprintf(
+				"[darkgray]4) [green]problems[lightgray] (count: %d)",
+				len(status.Diagnostics),
+			))
+			u.detailText[DetailProblemsPos] = problems
+			u.detailListItemWidget[DetailProblemsPos].Body.SetText(ansiText(problems))
+			u.detailListItemWidget[DetailProblemsPos].Body.ScrollToBeginning()
+
+			tests := FailedTestsText(status.Tests)
+			if tests == "" {
+				tests = "<none>"
+			}
+			u.detailListItemWidget[DetailTestsPos].Header.SetText(fmt.Sprintf(
+				"[darkgray]5) [green]tests[lightgray] (%s)",
+				TestSummary(status.Tests),
+			))
+			u.detailText[DetailTestsPos] = tests
+			u.detailListItemWidget[DetailTestsPos].Body.SetText(ansiText(tests))
+			u.detailListItemWidget[DetailTestsPos].Body.ScrollToBeginning()
+
 			u.focusWidget(u.detailListWidget)
 		}
 		return event
 	}
 
-	// Use KeyBackSpace2 because KeyBackspace is actually Ctrl-H (https://github.com/gdamore/tcell/statuses/127)
-	if event.Key() == tcell.KeyBackspace2 {
-		for pos := 0; pos < DetailListMaxLen; pos++ {
-			if u.activeWidget == u.detailListItemWidget[pos].Body {
-				u.focusWidget(u.detailListWidget)
+	return event
+}
+
+// openDetailView displays the detail list item's body in fullscreen along with the search input.
+func (u *UI) openDetailView(pos int) {
+	u.detailViewWidget.RemoveItem(u.detailListItemWidget[u.detailViewPos].Body)
+	u.detailViewWidget.RemoveItem(u.detailSearchWidget)
+
+	u.detailViewPos = pos
+	body := u.detailListItemWidget[pos].Body
+
+	u.detailViewWidget.AddItem(body, 0, 1, true)
+	u.detailViewWidget.AddItem(u.detailSearchWidget, 1, 0, false) // fixed height of 1
+
+	u.setDetailSearch(nil, "")
+	body.ScrollToEnd()
+	u.focusWidget(u.detailViewWidget)
+}
+
+// setDetailSearch marks all hits of the pattern in the detail view, and highlights the first one.
+//
+// If the pattern is nil, the current search is cleared.
+func (u *UI) setDetailSearch(re *regexp.Regexp, label string) {
+	u.detailSearchRe = re
+	u.detailSearchLabel = label
+	u.detailSearchHit = 0
+
+	var text string
+	text, u.detailSearchHits = markSearchHits(u.detailText[u.detailViewPos], re)
+	u.detailListItemWidget[u.detailViewPos].Body.SetText(text)
+
+	u.moveDetailSearch(0)
+}
+
+// moveDetailSearch highlights the hit which is delta positions away from the current one, wrapping around
+// at either end, and scrolls to it.
+func (u *UI) moveDetailSearch(delta int) {
+	body := u.detailListItemWidget[u.detailViewPos].Body
+
+	if u.detailSearchRe == nil {
+		body.Highlight()
+		u.detailSearchWidget.SetLabel("[darkgray]/: search, e: errors, l: locations, n/N: next/previous hit, o: open in editor")
+		u.detailSearchWidget.SetText("")
+		return
+	}
+
+	hitLen := len(u.detailSearchHits)
+	if hitLen == 0 {
+		body.Highlight()
+		u.detailSearchWidget.SetLabel(fmt.Sprintf("[darkgray]%s: no hits ", u.detailSearchLabel))
+		return
+	}
+
+	u.detailSearchHit = (u.detailSearchHit + delta + hitLen) % hitLen
+	body.Highlight(searchHitRegion(u.detailSearchHit))
+	body.ScrollToHighlight()
+
+	u.detailSearchWidget.SetLabel(fmt.Sprintf("[darkgray]%s (%d/%d): ", u.detailSearchLabel, u.detailSearchHit+1, hitLen))
+}
+
+// openLocation suspends the UI while the editor opens the "file:line" location in the highlighted hit,
+// or its line, or if there is no hit, the first location in the detail view.
+func (u *UI) openLocation() {
+	text := u.detailText[u.detailViewPos]
+
+	var loc Diagnostic
+	var ok bool
+	if u.detailSearchRe != nil && len(u.detailSearchHits) > 0 {
+		hit := u.detailSearchHits[u.detailSearchHit]
+		loc, ok = ParseLocation(text[hit[0]:hit[1]])
+		if !ok {
+			start := strings.LastIndex(text[:hit[0]], "\n") + 1
+			end := len(text)
+			if n := strings.Index(text[hit[0]:], "\n"); n != -1 {
+				end = hit[0] + n
 			}
+			loc, ok = ParseLocation(text[start:end])
 		}
+	} else {
+		loc, ok = ParseLocation(text)
 	}
-	return event
+	if !ok {
+		u.detailSearchWidget.SetLabel("[darkgray]no file:line location found ")
+		return
+	}
+
+	cmd, err := EditorCmd(u.Editor, loc, u.detailDir)
+	if err == nil {
+		u.app.Suspend(func() {
+			cmd.Stdin = os.Stdin
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			err = errors.Wrapf(cmd.Run(), "failed to run editor command [%s]", strings.Join(cmd.Args, " "))
+		})
+	}
+	if err != nil {
+		u.log.Error("failed to open location", cage_zap.Tag("ui"), zap.String("file", loc.File), zap.Error(err))
+		u.detailSearchWidget.SetLabel(fmt.Sprintf("[red]failed to open %s: %s ", loc.File, tview.Escape(err.Error())))
+	}
+}
+
+// markSearchHits returns the text prepared for display by ansiText, with each hit of the pattern underlined
+// and wrapped in a region which can be highlighted, and the start/end offsets of each hit.
+//
+// If the pattern is nil, the text is only prepared for display.
+func markSearchHits(text string, re *regexp.Regexp) (string, [][]int) {
+	if re == nil {
+		return ansiText(text), nil
+	}
+
+	var b strings.Builder
+	var hits [][]int
+	var last int
+	for _, loc := range re.FindAllStringIndex(text, -1) {
+		if loc[0] == loc[1] { // e.g. the pattern matched an empty line
+			continue
+		}
+		b.WriteString(ansiText(text[last:loc[0]]))
+		b.WriteString(`["` + searchHitRegion(len(hits)) + `"][::u]`)
+		b.WriteString(ansiText(text[loc[0]:loc[1]]))
+		b.WriteString(`[::-][""]`)
+		if loc[1] == len(text) || text[loc[1]] == '\n' {
+			// tview ignores tags at the end of a line, so the region would otherwise continue into the next one.
+			b.WriteString(" ")
+		}
+		last = loc[1]
+		hits = append(hits, loc)
+	}
+	b.WriteString(ansiText(text[last:]))
+
+	return b.String(), hits
+}
+
+// searchHitRegion returns the region Id of the search hit at the position.
+func searchHitRegion(pos int) string {
+	return "hit" + strconv.Itoa(pos)
+}
+
+// statuses returns a copy of the status list.
+func (u *UI) statuses() []Status {
+	u.statusListMu.RLock()
+	defer u.statusListMu.RUnlock()
+	return append([]Status{}, u.statusList...)
+}
+
+// selection returns a copy of the status list and the position of the selected item in it.
+func (u *UI) selection() ([]Status, int) {
+	u.statusListMu.RLock()
+	defer u.statusListMu.RUnlock()
+	statusList := append([]Status{}, u.statusList...)
+	for pos, status := range statusList {
+		if status.TargetId == u.selectedId {
+			return statusList, pos
+		}
+	}
+	return statusList, 0
+}
+
+// selectedPos returns the status list position of the selected item.
+func (u *UI) selectedPos() int {
+	_, pos := u.selection()
+	return pos
+}
+
+// selectedStatus returns the selected item, if the list is not empty.
+func (u *UI) selectedStatus() (Status, bool) {
+	statusList, pos := u.selection()
+	if pos >= len(statusList) {
+		return Status{}, false
+	}
+	return statusList[pos], true
+}
+
+// selectPos selects the item at the status list position, if it exists.
+//
+// It must run in the tview event loop, e.g. in a keyboard event handler, and draws the list directly
+// because QueueUpdateDraw would block the loop once the update queue is full.
+func (u *UI) selectPos(pos int) {
+	u.statusListMu.Lock()
+	if pos < 0 || pos >= len(u.statusList) {
+		u.statusListMu.Unlock()
+		return
+	}
+	u.selectedId = u.statusList[pos].TargetId
+	u.statusListMu.Unlock()
+
+	u.drawStatusList()
+}
+
+// rerunRequest returns a request to run the status's target again with the same trigger path and include.
+func (u *UI) rerunRequest(status Status) ExecRequest {
+	return NewRerunRequest(status, u.targetTree[status.TargetId], ExecCauseRerun)
+}
+
+// toggleMute mutes the target if it is not muted, and otherwise unmutes it.
+//
+// Like selectPos, it must run in the tview event loop.
+func (u *UI) toggleMute(targetId string) {
+	action := ExecMute
+	if u.muted[targetId] {
+		action = ExecUnmute
+		delete(u.muted, targetId)
+	} else {
+		u.muted[targetId] = true
+	}
+	u.sendExecRequest(ExecRequest{Action: action, Cause: "ui", TargetId: targetId})
+	u.drawStatusList()
+}
+
+// updateHistory calls the function with the target's run history, creating it if needed.
+func (u *UI) updateHistory(targetId string, f func(*targetHistory)) {
+	u.historyMu.Lock()
+	defer u.historyMu.Unlock()
+
+	h, ok := u.targetHistory[targetId]
+	if !ok {
+		h = &targetHistory{}
+		u.targetHistory[targetId] = h
+	}
+	f(h)
+}
+
+// history returns a copy of the target's run history, if any.
+func (u *UI) history(targetId string) (targetHistory, bool) {
+	u.historyMu.Lock()
+	defer u.historyMu.Unlock()
+
+	h, ok := u.targetHistory[targetId]
+	if !ok {
+		return targetHistory{}, false
+	}
+	return *h, true
+}
+
+// browserTarget returns the Target.Id displayed in the browserTable row.
+func (u *UI) browserTarget(row int) (string, bool) {
+	if row < 1 || row > len(u.browserRows) {
+		return "", false
+	}
+	return u.browserRows[row-1], true
+}
+
+// drawBrowser updates browserTable with one row per configured target which matches the filter.
+//
+// It must run in the tview event loop, e.g. inside QueueUpdateDraw or a keyboard event handler.
+func (u *UI) drawBrowser() {
+	if u.browserTable == nil {
+		return
+	}
+
+	selectedRow, _ := u.browserTable.GetSelection()
+	selectedId, _ := u.browserTarget(selectedRow)
+	statusList := u.statuses()
+
+	labels := make(map[string]string)
+	for _, t := range u.targets {
+		labels[t.Id] = t.Label
+	}
+
+	joinLabels := func(ids []string) string {
+		if len(ids) == 0 {
+			return "-"
+		}
+		var l []string
+		for _, id := range ids {
+			l = append(l, labels[id])
+		}
+		return strings.Join(l, ", ")
+	}
+
+	u.browserTable.Clear()
+	u.browserRows = u.browserRows[:0]
+
+	for col, title := range []string{"Target", "Result", "Last run", "Avg pass", "Flaky", "Paths", "Upstream", "Downstream", "Muted"} {
+		u.browserTable.SetCell(0, col, tview.NewTableCell(title).SetTextColor(tcell.ColorYellow).SetSelectable(false))
+	}
+
+	filter := strings.ToLower(strings.TrimSpace(u.browserFilter.GetText()))
+
+	for _, t := range u.targets {
+		if filter != "" && !strings.Contains(strings.ToLower(t.Label), filter) && !strings.Contains(strings.ToLower(t.Id), filter) {
+			continue
+		}
+
+		result, lastRun, avg, flaky, paths := "-", "-", "-", "-", "-"
+		resultStatus := TargetStatus("")
+
+		history, hasHistory := u.history(t.Id)
+		if hasHistory {
+			resultStatus = history.Result
+			result = string(history.Result)
+			if len(history.Flaky) > 0 {
+				result = strings.Join(history.Flaky, ", ")
+			}
+			if len(history.SkippedHandler) > 0 {
+				result += fmt.Sprintf(" (%d handler(s) skipped by If)", len(history.SkippedHandler))
+			}
+			lastRun = relativeTime(history.EndTime)
+			if history.PassCount > 0 {
+				avg = cage_time.DurationShort(history.PassRunLen / time.Duration(history.PassCount))
+				flaky = fmt.Sprintf("%d/%d", history.FlakyCount, history.PassCount)
+			}
+		}
+		for _, status := range statusList { // the current status takes precedence, e.g. started or resumed
+			if status.TargetId == t.Id {
+				resultStatus = status.Cause
+				result = string(status.Cause)
+				if !status.EndTime.IsZero() {
+					lastRun = relativeTime(status.EndTime)
+				}
+				break
+			}
+		}
+
+		resultColor := tcell.ColorDarkGray
+		switch resultStatus {
+		case TargetPassed:
+			resultColor = tcell.ColorGreen
+		case TargetFailed, TargetCanceled, TargetTimedOut:
+			resultColor = tcell.ColorRed
+		case TargetSkipped:
+			resultColor = tcell.ColorOrange
+		case TargetWarned:
+			resultColor = tcell.ColorYellow
+		}
+
+		if u.WatchedPathCount != nil && len(t.Include) > 0 {
+			paths = strconv.Itoa(u.WatchedPathCount(t.Id))
+		}
+
+		var downstream []string
+		for _, d := range t.Downstream {
+			downstream = append(downstream, d.Id)
+		}
+
+		muted := ""
+		if u.muted[t.Id] {
+			muted = "muted"
+		}
+
+		row := len(u.browserRows) + 1
+		u.browserTable.SetCell(row, 0, tview.NewTableCell(t.Label).SetTextColor(tcell.ColorWhite))
+		u.browserTable.SetCell(row, 1, tview.NewTableCell(result).SetTextColor(resultColor))
+		u.browserTable.SetCell(row, 2, tview.NewTableCell(lastRun).SetTextColor(tcell.ColorLightGray))
+		u.browserTable.SetCell(row, 3, tview.NewTableCell(avg).SetTextColor(tcell.ColorLightGray))
+		u.browserTable.SetCell(row, 4, tview.NewTableCell(flaky).SetTextColor(tcell.ColorLightGray))
+		u.browserTable.SetCell(row, 5, tview.NewTableCell(paths).SetTextColor(tcell.ColorLightGray))
+		u.browserTable.SetCell(row, 6, tview.NewTableCell(joinLabels(t.Upstream)).SetTextColor(tcell.ColorLightGray))
+		u.browserTable.SetCell(row, 7, tview.NewTableCell(joinLabels(downstream)).SetTextColor(tcell.ColorLightGray))
+		u.browserTable.SetCell(row, 8, tview.NewTableCell(muted).SetTextColor(tcell.ColorYellow))
+
+		u.browserRows = append(u.browserRows, t.Id)
+	}
+
+	// Keep the same target selected across updates if it still matches the filter.
+	row := 1
+	for n, id := range u.browserRows {
+		if id == selectedId {
+			row = n + 1
+			break
+		}
+	}
+	u.browserTable.Select(row, 0)
+}
+
+// ansiText prepares command output for display in a text view with dynamic colors enabled.
+//
+// ANSI escape sequences are translated into tview color tags, and existing text which resembles
+// tview tags, e.g. "[error]", is escaped so that it is displayed as-is.
+func ansiText(s string) string {
+	return tview.TranslateANSI(tview.Escape(s))
+}
+
+// relativeTime returns "now" for times less than a minute ago, otherwise a short duration, e.g. "5m ago".
+func relativeTime(t time.Time) string {
+	age := time.Since(t)
+	if age < time.Minute {
+		return "now"
+	}
+	return cage_time.DurationShort(age) + " ago"
+}
+
+// sendExecRequest sends the requests to the Dispatcher in a separate goroutine to keep the UI responsive.
+func (u *UI) sendExecRequest(reqs ...ExecRequest) {
+	if len(reqs) == 0 {
+		return
+	}
+	go func() {
+		for _, req := range reqs {
+			u.execReqCh <- req
+		}
+	}()
 }
 
 // focusWidget selects a widget to display and listen to for keyboard events.
@@ -0,0 +1,231 @@
+// Copyright (C) 2020 The boone Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package boone_test
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/codeactual/boone/internal/boone"
+	"github.com/codeactual/boone/internal/cage/testkit"
+)
+
+// initializedScreen lets tests send events to a screen before UI.Start, which would otherwise
+// initialize the screen at the same time.
+type initializedScreen struct {
+	tcell.SimulationScreen
+
+	// polls counts PollEvent calls, so tests can tell when all sent events were received.
+	polls *int64
+}
+
+func (initializedScreen) Init() error {
+	return nil
+}
+
+func (s initializedScreen) PollEvent() tcell.Event {
+	atomic.AddInt64(s.polls, 1)
+	return s.SimulationScreen.PollEvent()
+}
+
+// waitForPoll returns once the UI has polled the screen for its next event the expected number of times.
+//
+// Tests call it before the UI is stopped because tview does not end Run if Stop is called while it
+// handles an event.
+func waitForPoll(t *testing.T, polls *int64, expected int64) {
+	// Poll without require.Eventually because its ticker goroutine can send on a closed channel.
+	deadline := time.Now().Add(10 * time.Second)
+	for atomic.LoadInt64(polls) != expected {
+		require.True(t, time.Now().Before(deadline), "UI did not wait for another event")
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+type UISuite struct {
+	suite.Suite
+
+	ui *boone.UI
+
+	screen tcell.SimulationScreen
+
+	// polls is the initializedScreen.polls of screen.
+	polls int64
+
+	execReqCh     chan boone.ExecRequest
+	targetStartCh chan boone.Status
+	targetPassCh  chan boone.TargetPass
+	targetFailCh  chan boone.Status
+
+	// uiDone is closed when UI.Start returns startErr.
+	uiDone   chan struct{}
+	startErr error
+}
+
+func (suite *UISuite) SetupTest() {
+	var targets []boone.Target
+	for n := 0; n < 20; n++ {
+		id := fmt.Sprintf("t%d", n)
+		targets = append(targets, boone.Target{Id: id, Label: id + " label", Tree: []boone.TargetTree{{Id: id, Label: id + " label"}}})
+	}
+
+	suite.execReqCh = make(chan boone.ExecRequest, 100)
+	suite.targetStartCh = make(chan boone.Status)
+	suite.targetPassCh = make(chan boone.TargetPass)
+	suite.targetFailCh = make(chan boone.Status)
+
+	suite.ui = boone.NewUI(testkit.NewZapLogger(), targets, suite.execReqCh, suite.targetStartCh, suite.targetPassCh, suite.targetFailCh, nil)
+	suite.ui.Init()
+
+	suite.screen = tcell.NewSimulationScreen("UTF-8")
+	require.NoError(suite.T(), suite.screen.Init())
+	suite.screen.SetSize(80, 40)
+	suite.polls = 0
+	suite.ui.SetScreen(initializedScreen{SimulationScreen: suite.screen, polls: &suite.polls})
+
+	uiDone := make(chan struct{})
+	suite.uiDone = uiDone
+	go func() {
+		defer close(uiDone)
+		suite.startErr = suite.ui.Start()
+	}()
+
+	// Drain rerun requests so the UI never waits for a receiver.
+	execReqCh := suite.execReqCh
+	go func() {
+		for {
+			select {
+			case <-execReqCh:
+			case <-uiDone:
+				return
+			}
+		}
+	}()
+}
+
+func (suite *UISuite) TearDownTest() {
+	suite.ui.Stop()
+	<-suite.uiDone
+	require.NoError(suite.T(), suite.startErr)
+}
+
+// TestConcurrentUpdatesAndKeys changes the status list while keypresses read it, for detection by
+// "go test -race".
+func (suite *UISuite) TestConcurrentUpdatesAndKeys() {
+	t := suite.T()
+
+	keys := "jkr1Rmt"
+	keyRounds := 50
+	keyCount := int64(keyRounds * (len(keys) + 1))
+
+	keysDone := make(chan struct{})
+	go func() {
+		defer close(keysDone)
+		for n := 0; n < keyRounds; n++ {
+			for _, r := range keys {
+				suite.screen.PostEventWait(tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone))
+			}
+			suite.screen.PostEventWait(tcell.NewEventKey(tcell.KeyEscape, 0, tcell.ModNone))
+		}
+	}()
+
+	for n := 0; n < 200; n++ {
+		id := fmt.Sprintf("t%d", n%20)
+		suite.targetFailCh <- boone.Status{TargetId: id, TargetLabel: id + " label", HandlerLabel: "h", Cause: boone.TargetFailed, EndTime: time.Now()}
+		if n%3 == 0 {
+			suite.targetPassCh <- boone.TargetPass{TargetId: id}
+		}
+	}
+
+	select {
+	case <-keysDone:
+	case <-time.After(30 * time.Second): // each keypress redraws the screen, which is slow with -race
+		require.Fail(t, "keypresses were not processed")
+	}
+
+	waitForPoll(t, &suite.polls, keyCount+1)
+}
+
+// TestSessionWithoutReceiver asserts that statuses are still processed while sessions are not
+// received, and that the newest session is kept.
+func (suite *UISuite) TestSessionWithoutReceiver() {
+	t := suite.T()
+
+	for n := 0; n < 5; n++ {
+		id := fmt.Sprintf("t%d", n)
+		select {
+		case suite.targetFailCh <- boone.Status{TargetId: id, TargetLabel: id + " label", HandlerLabel: "h", Cause: boone.TargetFailed, EndTime: time.Now()}:
+		case <-time.After(10 * time.Second):
+			require.FailNow(t, "status was not received")
+		}
+	}
+
+	deadline := time.After(10 * time.Second)
+	for {
+		select {
+		case session := <-suite.ui.SessionCh():
+			if len(session.Statuses) < 5 {
+				continue
+			}
+			require.Len(t, session.Statuses, 5)
+			require.Exactly(t, "t4", session.Statuses[0].TargetId)
+		case <-deadline:
+			require.FailNow(t, "newest session was not received")
+		}
+		break
+	}
+
+	waitForPoll(t, &suite.polls, 1)
+}
+
+// TestSessionFlaky asserts that a pass which needed a retry is published even though passing
+// targets are removed from the status list.
+func (suite *UISuite) TestSessionFlaky() {
+	t := suite.T()
+
+	flaky := []string{"h passed on attempt 2/3"}
+	suite.targetPassCh <- boone.TargetPass{TargetId: "t3", Flaky: flaky}
+
+	select {
+	case session := <-suite.ui.SessionCh():
+		require.Empty(t, session.Statuses)
+		require.Len(t, session.Flaky, 1)
+		require.Exactly(t, boone.TargetPassed, session.Flaky[0].Cause)
+		require.Exactly(t, "t3 label", session.Flaky[0].TargetLabel)
+		require.Exactly(t, flaky, session.Flaky[0].Flaky)
+	case <-time.After(10 * time.Second):
+		require.FailNow(t, "session was not received")
+	}
+
+	// A later failure ends the flakiness.
+	suite.targetFailCh <- boone.Status{TargetId: "t3", TargetLabel: "t3 label", HandlerLabel: "h", Cause: boone.TargetFailed, EndTime: time.Now()}
+
+	deadline := time.After(10 * time.Second)
+	for {
+		select {
+		case session := <-suite.ui.SessionCh():
+			if len(session.Statuses) == 0 {
+				continue
+			}
+			require.Empty(t, session.Flaky)
+		case <-deadline:
+			require.FailNow(t, "session was not received")
+		}
+		break
+	}
+
+	waitForPoll(t, &suite.polls, 1)
+}
+
+func TestUISuite(t *testing.T) {
+	suite.Run(t, new(UISuite))
+}
@@ -59,6 +59,16 @@ func (w *Watcher) SetInclude(i map[string]cage_filepath.Glob) {
 	}
 }
 
+// PathCount returns how many file/dir paths are indexed for filtering write-activity, including
+// those discovered after startup.
+func (w *Watcher) PathCount() (n int) {
+	w.include.Range(func(k, v interface{}) bool {
+		n++
+		return true
+	})
+	return n
+}
+
 // Event receives activity descriptions from the filesystem monitor (Watcher.watcher).
 //
 // It implements ca/cage/os/file/watcher.Subscriber.
@@ -176,7 +186,7 @@ func (w *Watcher) Event(event watcher.Event) {
 
 	if sendExecReq {
 		w.ExecReqCh <- ExecRequest{
-			Cause:   "watcher",
+			Cause:   ExecCauseWatcher,
 			Event:   event,
 			Include: include,
 
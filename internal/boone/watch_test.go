@@ -777,7 +777,8 @@ func (suite *WatchSuite) TestHandlerCancelOnTimeout() {
 
 	actualStatus := <-targetFailCh
 	require.Exactly(t, target.Id, actualStatus.TargetId)
-	require.Exactly(t, "context deadline exceeded", actualStatus.Err)
+	require.Exactly(t, boone.TargetTimedOut, actualStatus.Cause)
+	require.Exactly(t, "Exec.Timeout [1s] reached at target 1/1 ["+target.Label+"] (passed: none; remaining: none)", actualStatus.Err)
 
 	time.Sleep(cage_exec.SigKillDelay)
 
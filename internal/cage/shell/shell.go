@@ -7,14 +7,40 @@
 package shell
 
 import (
+	"os"
+	"regexp"
+	"strings"
+
 	shellwords "github.com/mattn/go-shellwords"
 	"github.com/pkg/errors"
 )
 
+// envRe matches the variable references which shellwords would expand, e.g. $NAME and ${NAME}.
+var envRe = regexp.MustCompile(`\$({[a-zA-Z0-9_]+}|[a-zA-Z0-9_]+)`)
+
+// Quote returns the string as one single-quoted shell word, e.g. it's -> 'it'\''s'.
+//
+// It preserves spaces and quotes but not "$" if the word is later parsed by Parse, which expands
+// environment variables inside single quotes.
+func Quote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
 // Parse returns a slice of argument slices, one argument slice per pipeline process/stage.
+//
+// Environment variable references are expanded with os.Getenv.
 func Parse(s string) (args [][]string, err error) {
+	return ParseWithEnv(s, os.Getenv)
+}
+
+// ParseWithEnv returns a slice of argument slices, one argument slice per pipeline process/stage.
+//
+// Environment variable references are expanded with the input lookup function, e.g. to use the
+// environment of the parsed command instead of the current process. Like shellwords, each reference is
+// expanded after its argument is unquoted, so values are not split into multiple arguments.
+func ParseWithEnv(s string, getenv func(string) string) (args [][]string, err error) {
 	parser := shellwords.NewParser()
-	parser.ParseEnv = true // use os.GetEnv to expand variables
+	parser.ParseEnv = false // expand variables below with getenv instead of os.Getenv
 
 	args = [][]string{} // simplify test expectations, e.g. no nil slices expected
 
@@ -30,6 +56,16 @@ func Parse(s string) (args [][]string, err error) {
 			break
 		}
 
+		for n := range parsed {
+			parsed[n] = envRe.ReplaceAllStringFunc(parsed[n], func(ref string) string {
+				name := ref[1:]
+				if name[0] == '{' {
+					name = name[1 : len(name)-1]
+				}
+				return getenv(name)
+			})
+		}
+
 		args = append(args, parsed)
 
 		if parser.Position == -1 {
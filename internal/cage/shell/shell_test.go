@@ -55,3 +55,28 @@ func TestTable(t *testing.T) {
 		require.Exactly(t, c.expected, actual)
 	}
 }
+
+func TestParseWithEnv(t *testing.T) {
+	env := map[string]string{"OUT": "/tmp/some dir", "FLAG": "-v"}
+	getenv := func(name string) string { return env[name] }
+
+	actual, err := shell.ParseWithEnv(`tool $FLAG --out "$OUT" ${OUT}/x $MISSING | grep $FLAG`, getenv)
+	require.NoError(t, err)
+	require.Exactly(
+		t,
+		cage_strings.SliceOfSlice(
+			[]string{"tool", "-v", "--out", "/tmp/some dir", "/tmp/some dir/x", ""},
+			[]string{"grep", "-v"},
+		),
+		actual,
+	)
+}
+
+func TestQuote(t *testing.T) {
+	quoted := shell.Quote("it's a label")
+	require.Exactly(t, `'it'\''s a label'`, quoted)
+
+	actual, err := shell.Parse("echo " + quoted)
+	require.NoError(t, err)
+	require.Exactly(t, cage_strings.SliceOfSlice([]string{"echo", "it's a label"}), actual)
+}
@@ -0,0 +1,82 @@
+// Copyright (C) 2019 The CodeActual Go Environment Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package template
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+
+	cage_shell "github.com/codeactual/boone/internal/cage/shell"
+)
+
+// Funcs returns the functions available to templates expanded by this package.
+//
+// Arguments are ordered to support pipelines, e.g. {{.Path | trimSuffix ".go"}}.
+//
+//   - base PATH: last element of the path
+//   - dir PATH: all but the last element of the path
+//   - ext PATH: file name extension, including the dot
+//   - rel BASEPATH PATH: PATH relative to BASEPATH
+//   - trimPrefix PREFIX S, trimSuffix SUFFIX S: S without the leading/trailing string
+//   - split SEP S: list of the substrings of S between separators
+//   - join SEP LIST: LIST elements separated by SEP
+//   - quote S: S as one single-quoted shell word
+//   - env NAME [DEFAULT]: value of the environment variable, or DEFAULT if it is unset or empty
+//
+// The env function reads the process environment. Use FuncsWithEnv to read a different one.
+func Funcs() template.FuncMap {
+	return FuncsWithEnv(os.Getenv)
+}
+
+// FuncsWithEnv returns the functions from Funcs where env finds values with getenv, e.g. to read
+// the environment of a command instead of the process.
+func FuncsWithEnv(getenv func(string) string) template.FuncMap {
+	return template.FuncMap{
+		"base": filepath.Base,
+		"dir":  filepath.Dir,
+		"ext":  filepath.Ext,
+		"rel": func(basepath, targpath string) (string, error) {
+			rel, err := filepath.Rel(basepath, targpath)
+			if err != nil {
+				return "", errors.Wrapf(err, "failed to get path of [%s] relative to [%s]", targpath, basepath)
+			}
+			return rel, nil
+		},
+		"trimPrefix": func(prefix, s string) string {
+			return strings.TrimPrefix(s, prefix)
+		},
+		"trimSuffix": func(suffix, s string) string {
+			return strings.TrimSuffix(s, suffix)
+		},
+		"split": func(sep, s string) []string {
+			if s == "" {
+				return []string{}
+			}
+			return strings.Split(s, sep)
+		},
+		"join": func(sep string, list []string) string {
+			return strings.Join(list, sep)
+		},
+		"quote": cage_shell.Quote,
+		"env": func(name string, def ...string) (string, error) {
+			if len(def) > 1 {
+				return "", errors.Errorf("env [%s] accepts at most one default value, found %d", name, len(def))
+			}
+			if v := getenv(name); v != "" {
+				return v, nil
+			}
+			if len(def) == 1 {
+				return def[0], nil
+			}
+			return "", nil
+		},
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright (C) 2019 The CodeActual Go Environment Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package template_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	cage_template "github.com/codeactual/boone/internal/cage/text/template"
+)
+
+func TestFuncs(t *testing.T) {
+	require.NoError(t, os.Setenv("CAGE_TEMPLATE_TEST", "set"))
+	defer os.Unsetenv("CAGE_TEMPLATE_TEST")
+
+	data := map[string]string{
+		"Path":     "/proj/api/handler_test.go",
+		"Root":     "/proj",
+		"Packages": "example.com/api example.com/cmd",
+		"Label":    "it's a label",
+	}
+
+	cases := []struct {
+		body     string
+		expected string
+	}{
+		{body: "{{base .Path}}", expected: "handler_test.go"},
+		{body: "{{dir .Path}}", expected: "/proj/api"},
+		{body: "{{ext .Path}}", expected: ".go"},
+		{body: "{{rel .Root .Path}}", expected: "api/handler_test.go"},
+		{body: "./{{rel .Root .Path | dir}}", expected: "./api"},
+		{body: `{{.Path | base | trimSuffix "_test.go"}}`, expected: "handler"},
+		{body: `{{trimPrefix "/proj/" .Path}}`, expected: "api/handler_test.go"},
+		{body: `{{split " " .Packages | join ","}}`, expected: "example.com/api,example.com/cmd"},
+		{body: `{{split " " "" | join ","}}`, expected: ""},
+		{body: "{{quote .Label}}", expected: `'it'\''s a label'`},
+		{body: `{{env "CAGE_TEMPLATE_TEST"}}`, expected: "set"},
+		{body: `{{env "CAGE_TEMPLATE_TEST" "default"}}`, expected: "set"},
+		{body: `{{env "CAGE_TEMPLATE_TEST_UNSET"}}`, expected: ""},
+		{body: `{{env "CAGE_TEMPLATE_TEST_UNSET" "default"}}`, expected: "default"},
+	}
+	for _, c := range cases {
+		actual, err := cage_template.ExecuteBuffered(c.body, data)
+		require.NoError(t, err, c.body)
+		require.Exactly(t, c.expected, actual.String(), c.body)
+	}
+
+	_, err := cage_template.ExecuteBuffered(`{{env "A" "b" "c"}}`, data)
+	require.Error(t, err)
+
+	_, err = cage_template.ExecuteBuffered(`{{rel "relative" "/abs"}}`, data)
+	require.Error(t, err)
+
+	getenv := func(name string) string {
+		return map[string]string{"CAGE_TEMPLATE_TEST": "custom"}[name]
+	}
+	actual, err := cage_template.ExecuteBufferedFuncs(`{{env "CAGE_TEMPLATE_TEST"}} {{env "CAGE_TEMPLATE_TEST_UNSET" "default"}}`, data, cage_template.FuncsWithEnv(getenv))
+	require.NoError(t, err)
+	require.Exactly(t, "custom default", actual.String())
+}
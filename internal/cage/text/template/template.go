@@ -16,8 +16,14 @@ import (
 	cage_structs "github.com/codeactual/boone/internal/cage/structs"
 )
 
+// ExecuteBuffered expands the template body, which may use the functions from Funcs, with the input data.
 func ExecuteBuffered(body string, data interface{}) (b bytes.Buffer, err error) {
-	t, err := template.New("ExecuteBuffered").Parse(body)
+	return ExecuteBufferedFuncs(body, data, Funcs())
+}
+
+// ExecuteBufferedFuncs expands the template body, which may use the input functions, with the input data.
+func ExecuteBufferedFuncs(body string, data interface{}, funcs template.FuncMap) (b bytes.Buffer, err error) {
+	t, err := template.New("ExecuteBuffered").Funcs(funcs).Parse(body)
 	if err != nil {
 		return bytes.Buffer{}, errors.Wrapf(err, "failed to parse template [%s]", body)
 	}